@@ -0,0 +1,293 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file provides the mapping from /doc/codewalk/ requests to
+// "codewalks", which are XML-defined guided tours through the
+// source tree.
+
+package main
+
+import (
+	"bytes";
+	"fmt";
+	"io";
+	"log";
+	"os";
+	pathutil "path";
+	"regexp";
+	"strconv";
+	"strings";
+	"template";
+	"xml";
+	"http";
+)
+
+
+// A codewalk represents a single codewalk read from an XML file.
+type codewalk struct {
+	Title	string	"attr";
+	File	string;	// file name, relative to goroot
+	Steps	[]*codewalkStep	"Step";
+}
+
+
+// A codewalkStep is a single step in a codewalk.  XML is the
+// prose (as raw HTML) describing the step; file is the path to the
+// source file the step refers to, relative to goroot, and addr is
+// the address (see addrToByteRange) identifying the highlighted
+// range within that file.
+type codewalkStep struct {
+	Title	string	"attr";
+	Src	string	"attr";
+	file	string;	// Src with the address stripped off
+	addr	string;	// the part of Src after '#', if any
+	XML	string	"innerxml";
+	Lo, Hi	int;	// source offsets of the highlighted range
+}
+
+
+// ----------------------------------------------------------------------------
+// Codewalk directory
+
+// codewalkDirList returns the list of codewalk XML files in
+// $GOROOT/doc/codewalk.
+func codewalkDirList() []string {
+	const dir = "doc/codewalk";
+	list, err := io.ReadDir(pathutil.Join(goroot, dir));
+	if err != nil {
+		return nil;
+	}
+	var files []string;
+	for _, d := range list {
+		if d.IsRegular() && strings.HasSuffix(d.Name, ".xml") {
+			files = append(files, pathutil.Join(dir, d.Name));
+		}
+	}
+	return files;
+}
+
+
+// codewalkIndex serves the directory listing of all codewalks when
+// no particular codewalk is requested.
+func codewalkIndex(c *http.Conn, r *http.Request) {
+	type entry struct {
+		Path	string;
+		Title	string;
+	}
+
+	var list []entry;
+	for _, path := range codewalkDirList() {
+		cw, err := loadCodewalk(path);
+		if err != nil {
+			continue;
+		}
+		list = append(list, entry{path, cw.Title});
+	}
+
+	var buf bytes.Buffer;
+	if err := codewalkdirHtml.Execute(list, &buf); err != nil {
+		log.Stderrf("codewalkdirHtml.Execute: %s", err);
+	}
+	servePage(c, "Codewalks", "", buf.Bytes());
+}
+
+
+// ----------------------------------------------------------------------------
+// Loading and serving a single codewalk
+
+// loadCodewalk reads and parses the codewalk XML file named by
+// rel (relative to goroot), resolving each step's address into a
+// Lo/Hi byte range.
+func loadCodewalk(rel string) (*codewalk, os.Error) {
+	abs := pathutil.Join(goroot, rel);
+	data, err := io.ReadFile(abs);
+	if err != nil {
+		return nil, err;
+	}
+
+	cw := new(codewalk);
+	if err := xml.Unmarshal(bytes.NewBuffer(data), cw);
+	err != nil {
+		return nil, err;
+	}
+
+	for _, step := range cw.Steps {
+		file := step.Src;
+		addr := "";
+		if i := strings.Index(file, "#"); i >= 0 {
+			file, addr = file[0:i], file[i+1:];
+		}
+		step.file = file;
+		step.addr = addr;
+
+		src, err := io.ReadFile(pathutil.Join(goroot, file));
+		if err != nil {
+			step.Lo, step.Hi = 0, 0;
+			continue;
+		}
+		step.Lo, step.Hi = addrToByteRange(addr, 0, src);
+	}
+
+	return cw, nil;
+}
+
+
+// serveCodewalk serves the two-pane codewalk view: the ordered list
+// of steps on one side, and the highlighted source for the current
+// step on the other.
+func serveCodewalk(c *http.Conn, r *http.Request) {
+	if r.FormValue("fileprint") != "" {
+		serveCodewalkFile(c, r);
+		return;
+	}
+
+	rel := r.Url.Path[len("/doc/codewalk/"):len(r.Url.Path)];
+	if rel == "" {
+		codewalkIndex(c, r);
+		return;
+	}
+
+	cw, err := loadCodewalk(rel);
+	if err != nil {
+		log.Stderrf("loadCodewalk: %s", err);
+		http.NotFound(c, r);
+		return;
+	}
+
+	cur, err := strconv.Atoi(r.FormValue("step"));
+	if err != nil || cur < 0 || cur >= len(cw.Steps) {
+		cur = 0;
+	}
+
+	type Data struct {
+		Codewalk	*codewalk;
+		Cur		int;
+	}
+
+	var buf bytes.Buffer;
+	if err := codewalkHtml.Execute(&Data{cw, cur}, &buf); err != nil {
+		log.Stderrf("codewalkHtml.Execute: %s", err);
+	}
+	servePage(c, cw.Title, "", buf.Bytes());
+}
+
+
+// serveCodewalkFile implements the fileprint endpoint used to
+// lazy-load the highlighted source for a single step:
+// /doc/codewalk/fileprint?fileprint=<path>&lo=<n>&hi=<n>
+func serveCodewalkFile(c *http.Conn, r *http.Request) {
+	relpath := r.FormValue("fileprint");
+	lo, _ := strconv.Atoi(r.FormValue("lo"));
+	hi, _ := strconv.Atoi(r.FormValue("hi"));
+
+	src, err := io.ReadFile(pathutil.Join(goroot, relpath));
+	if err != nil {
+		log.Stderrf("%v", err);
+		http.NotFound(c, r);
+		return;
+	}
+
+	if hi > len(src) || hi <= 0 {
+		hi = len(src);
+	}
+	if lo < 0 || lo > hi {
+		lo = 0;
+	}
+
+	var buf bytes.Buffer;
+	fmt.Fprint(&buf, `<pre>`);
+	template.HtmlEscape(&buf, src[0:lo]);
+	fmt.Fprint(&buf, `<span class="highlight">`);
+	template.HtmlEscape(&buf, src[lo:hi]);
+	fmt.Fprint(&buf, `</span>`);
+	template.HtmlEscape(&buf, src[hi:len(src)]);
+	fmt.Fprint(&buf, `</pre>`);
+
+	c.SetHeader("content-type", "text/html; charset=utf-8");
+	c.Write(buf.Bytes());
+}
+
+
+// ----------------------------------------------------------------------------
+// Address resolution
+//
+// An address has one of the following forms:
+//
+//	n	line number n, 1-indexed
+//	n,m	line range n through m, inclusive
+//	/re/	the first match of regexp re
+//	+n	n bytes after the previous address
+//	-n	n bytes before the previous address
+//
+// addrToByteRange resolves addr, relative to the previous match
+// ([prevLo, prevLo] if there is none), against src and returns the
+// resulting byte offsets.
+
+var addrRE = regexp.MustCompile(`^([0-9]+)(,([0-9]+))?$`)
+
+func addrToByteRange(addr string, prevLo int, src []byte) (lo, hi int) {
+	if addr == "" {
+		return 0, len(src);
+	}
+
+	switch addr[0] {
+	case '/':
+		re, err := regexp.Compile(strings.Trim(addr, "/"));
+		if err != nil {
+			return prevLo, prevLo;
+		}
+		start := re.FindIndex(src[prevLo:len(src)]);
+		if start == nil {
+			return prevLo, prevLo;
+		}
+		return prevLo + start[0], prevLo + start[1];
+
+	case '+', '-':
+		n, err := strconv.Atoi(addr[1:len(addr)]);
+		if err != nil {
+			return prevLo, prevLo;
+		}
+		if addr[0] == '-' {
+			n = -n;
+		}
+		pos := prevLo + n;
+		if pos < 0 {
+			pos = 0;
+		}
+		if pos > len(src) {
+			pos = len(src);
+		}
+		return pos, pos;
+	}
+
+	if m := addrRE.FindStringSubmatch(addr); m != nil {
+		startLine, _ := strconv.Atoi(m[1]);
+		endLine := startLine;
+		if m[3] != "" {
+			endLine, _ = strconv.Atoi(m[3]);
+		}
+		return lineRangeToByteRange(src, startLine, endLine);
+	}
+
+	return prevLo, prevLo;
+}
+
+
+// lineRangeToByteRange converts a 1-indexed, inclusive line range
+// into a byte offset range within src.
+func lineRangeToByteRange(src []byte, startLine, endLine int) (lo, hi int) {
+	line := 1;
+	for offs, b := range src {
+		if line == startLine && lo == 0 {
+			lo = offs;
+		}
+		if b == '\n' {
+			line++;
+			if line > endLine {
+				return lo, offs + 1;
+			}
+		}
+	}
+	return lo, len(src);
+}
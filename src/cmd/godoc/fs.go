@@ -0,0 +1,312 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file defines the FileSystem interface used by godoc to read
+// package and command source. Abstracting away os.* lets godoc serve
+// its tree from something other than a plain directory - for example
+// a prebuilt $GOROOT.zip, or a zip base overlaid with extra roots.
+
+package main
+
+import (
+	"archive/zip";
+	"io";
+	"os";
+	pathutil "path";
+	"sort";
+	"strings";
+)
+
+
+// ReadSeekCloser is the subset of *os.File's methods that FileSystem
+// implementations must be able to produce for an open file.
+type ReadSeekCloser interface {
+	io.Reader;
+	io.Seeker;
+	io.Closer;
+}
+
+
+// FileSystem is the interface accessed by godoc to read the package
+// and command source trees. Every implementation must be safe for
+// concurrent use by multiple goroutines.
+type FileSystem interface {
+	Open(path string) (ReadSeekCloser, os.Error);
+	Lstat(path string) (*os.Dir, os.Error);
+	Stat(path string) (*os.Dir, os.Error);
+	ReadDir(path string) ([]os.Dir, os.Error);
+	String() string;
+}
+
+
+// ----------------------------------------------------------------------------
+// OS-backed file system
+
+// OS returns a FileSystem backed by the tree rooted at root,
+// using the local operating system's file system calls.
+func OS(root string) FileSystem {
+	return osFS(root);
+}
+
+type osFS string
+
+func (root osFS) resolve(path string) string {
+	// Clean the path so that it cannot escape the root via "..".
+	path = pathutil.Clean("/" + path);
+	return pathutil.Join(string(root), path);
+}
+
+func (root osFS) Open(path string) (ReadSeekCloser, os.Error) {
+	f, err := os.Open(root.resolve(path), os.O_RDONLY, 0);
+	if err != nil {
+		return nil, err;
+	}
+	return f, nil;
+}
+
+func (root osFS) Lstat(path string) (*os.Dir, os.Error) {
+	return os.Lstat(root.resolve(path));
+}
+
+func (root osFS) Stat(path string) (*os.Dir, os.Error) {
+	return os.Stat(root.resolve(path));
+}
+
+func (root osFS) ReadDir(path string) ([]os.Dir, os.Error) {
+	return io.ReadDir(root.resolve(path));
+}
+
+func (root osFS) String() string {
+	return "OS(" + string(root) + ")";
+}
+
+
+// ----------------------------------------------------------------------------
+// Zip-backed, read-only file system
+
+// ZipFS returns a FileSystem rooted at root within the zip archive
+// read by rc. It is read-only: Open never returns a writable file.
+func ZipFS(rc *zip.Reader, root string) FileSystem {
+	return &zipFS{rc, pathutil.Clean(root)};
+}
+
+type zipFS struct {
+	reader	*zip.Reader;
+	root	string;
+}
+
+func (fs *zipFS) resolve(path string) string {
+	path = pathutil.Clean("/" + path);
+	full := pathutil.Join(fs.root, path);
+	return strings.TrimLeft(full, "/");
+}
+
+func (fs *zipFS) findFile(path string) *zip.File {
+	name := fs.resolve(path);
+	for _, f := range fs.reader.File {
+		if strings.TrimRight(f.Name, "/") == name {
+			return f;
+		}
+	}
+	return nil;
+}
+
+func (fs *zipFS) Open(path string) (ReadSeekCloser, os.Error) {
+	f := fs.findFile(path);
+	if f == nil {
+		return nil, os.ENOENT;
+	}
+	rc, err := f.Open();
+	if err != nil {
+		return nil, err;
+	}
+	return &zipFile{rc, f}, nil;
+}
+
+// zipFile adapts a zip.ReadCloser (which is not seekable) to the
+// ReadSeekCloser interface; it reads sequentially only, and its Seek
+// always fails.
+type zipFile struct {
+	io.ReadCloser;
+	entry	*zip.File;
+}
+
+func (f *zipFile) Seek(offset int64, whence int) (int64, os.Error) {
+	// Seeking within a zip entry is not supported; callers of this
+	// file system only ever read sequentially.
+	return 0, os.NewError("zipFile: Seek not supported");
+}
+
+func (fs *zipFS) zipStat(path string) (*os.Dir, os.Error) {
+	name := fs.resolve(path);
+	for _, f := range fs.reader.File {
+		trimmed := strings.TrimRight(f.Name, "/");
+		if trimmed == name {
+			d := new(os.Dir);
+			d.Name = pathutil.Base(name);
+			if strings.HasSuffix(f.Name, "/") {
+				d.Mode = os.ModeDir;
+			}
+			d.Size = int64(f.UncompressedSize);
+			return d, nil;
+		}
+	}
+	// synthesize a directory entry if name is a non-empty prefix
+	// of some archive member
+	prefix := name + "/";
+	for _, f := range fs.reader.File {
+		if strings.HasPrefix(f.Name, prefix) {
+			d := new(os.Dir);
+			d.Name = pathutil.Base(name);
+			d.Mode = os.ModeDir;
+			return d, nil;
+		}
+	}
+	return nil, os.ENOENT;
+}
+
+func (fs *zipFS) Lstat(path string) (*os.Dir, os.Error) {
+	return fs.zipStat(path);
+}
+
+func (fs *zipFS) Stat(path string) (*os.Dir, os.Error) {
+	return fs.zipStat(path);
+}
+
+func (fs *zipFS) ReadDir(path string) ([]os.Dir, os.Error) {
+	prefix := fs.resolve(path);
+	if prefix != "" {
+		prefix += "/";
+	}
+
+	seen := make(map[string]bool);
+	var list []os.Dir;
+	for _, f := range fs.reader.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			continue;
+		}
+		rest := f.Name[len(prefix):len(f.Name)];
+		if rest == "" {
+			continue;
+		}
+		if i := strings.Index(rest, "/"); i >= 0 {
+			rest = rest[0 : i+1];	// keep trailing '/' to mark a directory
+		}
+		if seen[rest] {
+			continue;
+		}
+		seen[rest] = true;
+
+		d := os.Dir{};
+		if strings.HasSuffix(rest, "/") {
+			d.Name = rest[0 : len(rest)-1];
+			d.Mode = os.ModeDir;
+		} else {
+			d.Name = rest;
+			d.Size = int64(f.UncompressedSize);
+		}
+		list = append(list, d);
+	}
+
+	sort.Sort(dirList(list));
+	return list, nil;
+}
+
+func (fs *zipFS) String() string {
+	return "ZipFS(" + fs.root + ")";
+}
+
+type dirList []os.Dir
+
+func (l dirList) Len() int		{ return len(l) }
+func (l dirList) Less(i, j int) bool	{ return l[i].Name < l[j].Name }
+func (l dirList) Swap(i, j int)		{ l[i], l[j] = l[j], l[i] }
+
+
+// ----------------------------------------------------------------------------
+// NameSpace: an overlay of multiple file systems bound into one tree
+
+// A NameSpace is a FileSystem that binds other file systems under
+// mount points in its own path space, so that e.g. a zip-backed GOROOT
+// can be overlaid with one or more -path roots mounted at
+// <pkgroot>/<rootname> (served as /pkg/<rootname>).
+// Later binds shadow earlier ones at the same mount point.
+type NameSpace struct {
+	binds	[]nsbind;
+}
+
+type nsbind struct {
+	prefix	string;
+	fs	FileSystem;
+}
+
+// NewNameSpace returns an empty overlay file system.
+func NewNameSpace() *NameSpace {
+	return new(NameSpace);
+}
+
+// Bind adds fs to the name space so that paths under prefix are
+// resolved (with prefix stripped) against fs. Binds added later take
+// priority over earlier ones that share the same prefix.
+func (ns *NameSpace) Bind(prefix string, fs FileSystem) {
+	ns.binds = append(ns.binds, nsbind{pathutil.Clean("/" + prefix), fs});
+}
+
+// resolve finds the most recently added bind covering path and
+// returns the underlying file system together with the path relative
+// to that bind's mount point. Binds are tried most-recently-added
+// first so later roots shadow earlier ones; a later bind at "/"
+// therefore shadows every earlier bind.
+func (ns *NameSpace) resolve(path string) (FileSystem, string, bool) {
+	path = pathutil.Clean("/" + path);
+	for i := len(ns.binds) - 1; i >= 0; i-- {
+		b := ns.binds[i];
+		if b.prefix == "/" {
+			return b.fs, path, true;
+		}
+		if path == b.prefix {
+			return b.fs, "/", true;
+		}
+		if strings.HasPrefix(path, b.prefix+"/") {
+			return b.fs, path[len(b.prefix):len(path)], true;
+		}
+	}
+	return nil, "", false;
+}
+
+func (ns *NameSpace) Open(path string) (ReadSeekCloser, os.Error) {
+	fs, rest, ok := ns.resolve(path);
+	if !ok {
+		return nil, os.ENOENT;
+	}
+	return fs.Open(rest);
+}
+
+func (ns *NameSpace) Lstat(path string) (*os.Dir, os.Error) {
+	fs, rest, ok := ns.resolve(path);
+	if !ok {
+		return nil, os.ENOENT;
+	}
+	return fs.Lstat(rest);
+}
+
+func (ns *NameSpace) Stat(path string) (*os.Dir, os.Error) {
+	fs, rest, ok := ns.resolve(path);
+	if !ok {
+		return nil, os.ENOENT;
+	}
+	return fs.Stat(rest);
+}
+
+func (ns *NameSpace) ReadDir(path string) ([]os.Dir, os.Error) {
+	fs, rest, ok := ns.resolve(path);
+	if !ok {
+		return nil, os.ENOENT;
+	}
+	return fs.ReadDir(rest);
+}
+
+func (ns *NameSpace) String() string {
+	return "NameSpace";
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"archive/zip";
 	"bytes";
 	"flag";
 	"fmt";
@@ -83,11 +84,41 @@ var (
 
 	// layout control
 	tabwidth	= flag.Int("tabwidth", 4, "tab width");
+
+	// zip file backing the root file system, if any
+	zipfile	= flag.String("zip", "", "zip file providing the file system to serve; disabled if empty");
+
+	// additional package roots, outside goroot
+	pkgpath	= flag.String("path", "", "additional package source roots, colon-separated; each is surfaced under /pkg/<rootname>");
+
+	// package filter
+	filter		= flag.String("filter", "", "filter file with permitted directory paths, relative to goroot; disabled if empty");
+	filter_minutes	= flag.Int("filter_minutes", 10, "minutes between filter file reloads");
 )
 
+// filterDelay tracks the current retry interval (in minutes) for
+// re-reading the filter file; it backs off exponentially on read
+// errors and resets to *filter_minutes on success.
+var filterDelay delayTime
+
+const maxFilterDelay = 24 * 60	// widen backoff at most to once a day
+
 
 var fsTree RWValue	// *Directory tree of packages, updated with each sync
 
+var fs FileSystem	// the file system godoc reads source from; set up in setupGoroot
+
+// packageFilter holds the current map[string]bool of directory
+// paths (relative to goroot) permitted to appear in fsTree and the
+// search index. A nil value (the default, or when -filter is not
+// set) means no filtering is in effect.
+var packageFilter RWValue
+
+// extraRoots holds the names of the additional package roots
+// contributed by -path, in the order they were bound; used to list
+// them on the /pkg/ landing page.
+var extraRoots []string
+
 
 func init() {
 	goroot = os.Getenv("GOROOT");
@@ -98,6 +129,115 @@ func init() {
 }
 
 
+// setupGoroot initializes fs, the file system godoc reads all source
+// and templates from. If -zip is set, the root file system is read
+// from the zip archive at that path (e.g. a prebuilt $GOROOT.zip for
+// a frozen or sandboxed deployment); otherwise the local operating
+// system tree rooted at goroot is used directly. If -path is set,
+// each colon-separated entry is bound under /pkg/<rootname> atop the
+// goroot-rooted base, so that additional packages outside GOROOT can
+// be browsed alongside the standard library.
+func setupGoroot() {
+	var base FileSystem;
+	if *zipfile == "" {
+		base = OS(goroot);
+	} else {
+		rc, err := zip.OpenReader(*zipfile);
+		if err != nil {
+			log.Exitf("zip.OpenReader(%s): %v", *zipfile, err);
+		}
+		base = ZipFS(&rc.Reader, goroot);
+	}
+
+	if *pkgpath == "" {
+		fs = base;
+		return;
+	}
+
+	ns := NewNameSpace();
+	ns.Bind("/", base);
+	seen := make(map[string]bool);
+	for _, root := range strings.Split(*pkgpath, ":", -1) {
+		if root == "" {
+			continue;
+		}
+		_, name := pathutil.Split(pathutil.Clean(root));
+		if seen[name] {
+			log.Stderrf("-path: %s shadows an earlier root named %q", root, name);
+		}
+		seen[name] = true;
+		ns.Bind(pathutil.Join(*pkgroot, name), OS(root));
+		extraRoots = append(extraRoots, name);
+	}
+	fs = ns;
+}
+
+
+// readFilterFile reads *filter - a newline-separated list of
+// directory paths relative to goroot - and returns the set of
+// permitted paths.
+func readFilterFile() (map[string]bool, os.Error) {
+	data, err := readFile(*filter);
+	if err != nil {
+		return nil, err;
+	}
+
+	set := make(map[string]bool);
+	for _, line := range strings.Split(string(data), "\n", -1) {
+		line = strings.TrimSpace(line);
+		if line != "" {
+			set[pathutil.Clean(line)] = true;
+		}
+	}
+	return set, nil;
+}
+
+
+// filterRefresh periodically re-reads the filter file named by
+// *filter. On success the new filter set is installed and the
+// reload delay resets to *filter_minutes; on error the delay backs
+// off exponentially (via filterDelay.backoff) up to maxFilterDelay,
+// so a missing or broken filter file doesn't spam the logs.
+func filterRefresh() {
+	filterDelay.set(*filter_minutes);
+	for {
+		set, err := readFilterFile();
+		if err != nil {
+			log.Stderrf("readFilterFile(%s): %v", *filter, err);
+			filterDelay.backoff(maxFilterDelay);
+		} else {
+			packageFilter.set(set);
+			filterDelay.set(*filter_minutes);
+			fsTree.set(nil);	// trigger a re-sync and re-index
+		}
+
+		delay, _ := filterDelay.get();
+		time.Sleep(int64(delay.(int)) * 60e9);
+	}
+}
+
+
+// setupFilter starts the filter-file refresh goroutine if -filter
+// is set; otherwise packageFilter stays nil and no filtering occurs.
+func setupFilter() {
+	if *filter != "" {
+		go filterRefresh();
+	}
+}
+
+
+// filteredOut reports whether path (relative to goroot) should be
+// excluded from fsTree and the search index because a filter file is
+// active and does not list it.
+func filteredOut(path string) bool {
+	set, _ := packageFilter.get();
+	if set == nil {
+		return false;	// no filter in effect
+	}
+	return !set.(map[string]bool)[pathutil.Clean(path)];
+}
+
+
 // ----------------------------------------------------------------------------
 // Predicates and small utility functions
 
@@ -135,6 +275,18 @@ func htmlEscape(s string) string {
 }
 
 
+// readFile reads the full contents of the file named by path from
+// fs, the active FileSystem.
+func readFile(path string) ([]byte, os.Error) {
+	f, err := fs.Open(path);
+	if err != nil {
+		return nil, err;
+	}
+	defer f.Close();
+	return io.ReadAll(f);
+}
+
+
 func firstSentence(s string) string {
 	i := strings.Index(s, ". ");
 	if i < 0 {
@@ -159,6 +311,10 @@ type Directory struct {
 
 
 func newDirTree(path, name string, depth int) *Directory {
+	if filteredOut(path) {
+		return nil;
+	}
+
 	if depth <= 0 {
 		// return a dummy directory so that the parent directory
 		// doesn't get discarded just because we reached the max
@@ -166,7 +322,7 @@ func newDirTree(path, name string, depth int) *Directory {
 		return &Directory{path, name, "", nil};
 	}
 
-	list, _ := io.ReadDir(path);	// ignore errors
+	list, _ := fs.ReadDir(path);	// ignore errors
 
 	// determine number of subdirectories and package files
 	ndirs := 0;
@@ -174,7 +330,7 @@ func newDirTree(path, name string, depth int) *Directory {
 	text := "";
 	for _, d := range list {
 		switch {
-		case isPkgDir(d):
+		case isPkgDir(d) && !filteredOut(pathutil.Join(path, d.Name)):
 			ndirs++;
 		case isPkgFile(d):
 			nfiles++;
@@ -223,7 +379,7 @@ func newDirTree(path, name string, depth int) *Directory {
 // subdirectories containing package files (transitively).
 //
 func newDirectory(root string, depth int) *Directory {
-	d, err := os.Lstat(root);
+	d, err := fs.Lstat(root);
 	if err != nil || !isPkgDir(d) {
 		return nil;
 	}
@@ -282,7 +438,7 @@ type parseErrors struct {
 // a sorted list (by file position) of errors, if any.
 //
 func parse(path string, mode uint) (*ast.File, *parseErrors) {
-	src, err := io.ReadFile(path);
+	src, err := readFile(path);
 	if err != nil {
 		log.Stderrf("%v", err);
 		errs := []parseError{parseError{nil, 0, err.String()}};
@@ -322,6 +478,43 @@ func parse(path string, mode uint) (*ast.File, *parseErrors) {
 }
 
 
+// parsePackage reads the .go files in dirname that satisfy filter
+// through fs (unlike parser.ParsePackage, which only ever looks at
+// the real OS file system) and assembles the result into an
+// *ast.Package, so that packages bound into fs from an extra -path
+// root, a zip archive, etc. parse correctly. It returns nil, nil if
+// dirname contains no matching files - not an error, since that's
+// simply a directory with no package to document.
+func parsePackage(dirname, pkgname string, filter func(*os.Dir) bool, mode uint) (*ast.Package, os.Error) {
+	list, err := fs.ReadDir(dirname);
+	if err != nil {
+		return nil, err;
+	}
+
+	files := make(map[string]*ast.File);
+	for _, d := range list {
+		if !filter(&d) {
+			continue;
+		}
+		filename := pathutil.Join(dirname, d.Name);
+		src, err := readFile(filename);
+		if err != nil {
+			return nil, err;
+		}
+		file, err := parser.ParseFile(filename, src, mode);
+		if err != nil {
+			return nil, err;
+		}
+		files[filename] = file;
+	}
+	if len(files) == 0 {
+		return nil, nil;
+	}
+
+	return &ast.Package{Name: pkgname, Files: files}, nil;
+}
+
+
 // ----------------------------------------------------------------------------
 // HTML formatting support
 
@@ -539,7 +732,7 @@ var fmap = template.FormatterMap{
 
 func readTemplate(name string) *template.Template {
 	path := pathutil.Join(*tmplroot, name);
-	data, err := io.ReadFile(path);
+	data, err := readFile(path);
 	if err != nil {
 		log.Exitf("ReadFile %s: %v", path, err);
 	}
@@ -552,7 +745,9 @@ func readTemplate(name string) *template.Template {
 
 
 var (
-	dirsHtml,
+	codewalkHtml,
+		codewalkdirHtml,
+		dirsHtml,
 		godocHtml,
 		listingHtml,
 		packageHtml,
@@ -565,6 +760,8 @@ var (
 func readTemplates() {
 	// have to delay until after flags processing,
 	// so that main has chdir'ed to goroot.
+	codewalkHtml = readTemplate("codewalk.html");
+	codewalkdirHtml = readTemplate("codewalkdir.html");
 	dirsHtml = readTemplate("dirs.html");
 	godocHtml = readTemplate("godoc.html");
 	listingHtml = readTemplate("listing.html");
@@ -585,6 +782,7 @@ func servePage(c *http.Conn, title, query string, content []byte) {
 		Timestamp	string;
 		Query		string;
 		Content		[]byte;
+		PkgRoots	[]string;	// extra package roots contributed by -path
 	}
 
 	_, ts := fsTree.get();
@@ -593,6 +791,7 @@ func servePage(c *http.Conn, title, query string, content []byte) {
 		Timestamp: time.SecondsToLocalTime(ts).String(),
 		Query: query,
 		Content: content,
+		PkgRoots: extraRoots,
 	};
 
 	if err := godocHtml.Execute(&d, c); err != nil {
@@ -628,8 +827,7 @@ func commentText(src []byte) (text string) {
 
 func serveHtmlDoc(c *http.Conn, r *http.Request, filename string) {
 	// get HTML body contents
-	path := pathutil.Join(goroot, filename);
-	src, err := io.ReadFile(path);
+	src, err := readFile(filename);
 	if err != nil {
 		log.Stderrf("%v", err);
 		http.NotFound(c, r);
@@ -637,7 +835,7 @@ func serveHtmlDoc(c *http.Conn, r *http.Request, filename string) {
 	}
 
 	// if it's the language spec, add tags to EBNF productions
-	if strings.HasSuffix(path, "go_spec.html") {
+	if strings.HasSuffix(filename, "go_spec.html") {
 		var buf bytes.Buffer;
 		linkify(&buf, src);
 		src = buf.Bytes();
@@ -659,8 +857,7 @@ func serveParseErrors(c *http.Conn, errors *parseErrors) {
 
 
 func serveGoSource(c *http.Conn, filename string, styler printer.Styler) {
-	path := pathutil.Join(goroot, filename);
-	prog, errors := parse(path, parser.ParseComments);
+	prog, errors := parse(filename, parser.ParseComments);
 	if errors != nil {
 		serveParseErrors(c, errors);
 		return;
@@ -690,7 +887,7 @@ func serveDirectory(c *http.Conn, r *http.Request) {
 	}
 
 	path := pathutil.Join(".", r.Url.Path);
-	list, err := io.ReadDir(path);
+	list, err := fs.ReadDir(path);
 	if err != nil {
 		http.NotFound(c, r);
 		return;
@@ -726,7 +923,7 @@ func serveFile(c *http.Conn, r *http.Request) {
 		serveGoSource(c, path, &Styler{highlight: r.FormValue("h")});
 
 	default:
-		dir, err := os.Lstat(pathutil.Join(".", path));
+		dir, err := fs.Lstat(pathutil.Join(".", path));
 		if err != nil {
 			http.NotFound(c, r);
 			return;
@@ -753,6 +950,20 @@ type PageInfo struct {
 	PDoc	*doc.PackageDoc;	// nil if no package found
 	Dirs	*Directory;		// nil if no directory information found
 	IsPkg	bool;			// false if this is not documenting a real package
+	Examples	[]Example;	// testable examples extracted from the package's _test.go files
+}
+
+
+// An Example documents an ExampleXxx function found in the package's
+// test files (ExampleXxx, ExampleXxx_Yyy, or ExampleXxx_Yyy_Zzz, per
+// the testing package's naming convention). Name is the part after
+// "Example", used by package.html to match the example to the
+// identifier it documents (empty for a whole-package example).
+type Example struct {
+	Name	string;
+	Doc	string;
+	Code	string;
+	Output	string;	// from a trailing "// Output:" comment, if any
 }
 
 
@@ -792,10 +1003,10 @@ func (h *httpHandler) getPageInfo(path string) PageInfo {
 	};
 
 	// get package AST
-	pkg, err := parser.ParsePackage(dirname, filter, parser.ParseComments);
+	pkg, err := parsePackage(dirname, pkgname, filter, parser.ParseComments);
 	if err != nil {
 		// TODO: parse errors should be shown instead of an empty directory
-		log.Stderrf("parser.parsePackage: %s", err);
+		log.Stderrf("parsePackage: %s", err);
 	}
 
 	// compute package documentation
@@ -818,7 +1029,91 @@ func (h *httpHandler) getPageInfo(path string) PageInfo {
 		dir = newDirectory(dirname, 1);
 	}
 
-	return PageInfo{pdoc, dir, h.isPkg};
+	// collect testable examples from the package's _test.go files;
+	// isPkgFile excludes them, so they are gathered separately
+	examples := collectExamples(pkgname, dirname);
+
+	return PageInfo{pdoc, dir, h.isPkg, examples};
+}
+
+
+// collectExamples parses every *_test.go file in dirname belonging
+// to pkgname (or pkgname + "_test") and returns its ExampleXxx
+// functions as a slice of Example.
+func collectExamples(pkgname, dirname string) []Example {
+	list, _ := fs.ReadDir(dirname);	// ignore errors
+
+	var examples []Example;
+	for _, d := range list {
+		if !isGoFile(d) || !strings.HasSuffix(d.Name, "_test.go") {
+			continue;
+		}
+
+		path := pathutil.Join(dirname, d.Name);
+		file, err := parser.ParseFile(path, nil, parser.ParseComments);
+		if err != nil || file.Name.Value != pkgname && file.Name.Value != pkgname+"_test" {
+			continue;
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl);
+			if !ok || fn.Recv != nil || fn.Body == nil {
+				continue;
+			}
+			name := fn.Name.Value;
+			if !strings.HasPrefix(name, "Example") {
+				continue;
+			}
+
+			code, output := splitExampleOutput(fn.Body);
+			examples = append(examples, Example{
+				Name: name[len("Example"):len(name)],
+				Doc: doc.CommentText(fn.Doc),
+				Code: code,
+				Output: output,
+			});
+		}
+	}
+	return examples;
+}
+
+
+// splitExampleOutput renders body - formatted via writeNode the same
+// way source listings are, so it matches the rest of the page - then
+// strips the outer braces and one level of indentation, and pulls
+// off a trailing "// Output:" comment as the expected output.
+func splitExampleOutput(body *ast.BlockStmt) (code, output string) {
+	var buf bytes.Buffer;
+	writeNode(&buf, body, false, &defaultStyler);
+	s := strings.TrimSpace(buf.String());
+	if len(s) >= 2 && s[0] == '{' && s[len(s)-1] == '}' {
+		s = strings.TrimSpace(s[1 : len(s)-1]);
+	}
+
+	lines := strings.Split(s, "\n", -1);
+	for i, line := range lines {
+		if strings.HasPrefix(line, "\t") {
+			lines[i] = line[1:len(line)];
+		}
+	}
+
+	const marker = "// Output:";
+	for i, line := range lines {
+		if strings.TrimSpace(line) == marker {
+			code = strings.TrimSpace(strings.Join(lines[0:i], "\n"));
+			var out bytes.Buffer;
+			for _, outline := range lines[i+1 : len(lines)] {
+				outline = strings.TrimSpace(outline);
+				outline = strings.TrimSpace(strings.TrimLeft(outline, "/"));
+				fmt.Fprintln(&out, outline);
+			}
+			output = strings.TrimSpace(out.String());
+			return;
+		}
+	}
+
+	code = strings.TrimSpace(strings.Join(lines, "\n"));
+	return;
 }
 
 
@@ -832,12 +1127,17 @@ func (h *httpHandler) ServeHTTP(c *http.Conn, r *http.Request) {
 	info := h.getPageInfo(path);
 
 	var buf bytes.Buffer;
-	if r.FormValue("f") == "text" {
+	switch r.FormValue("f") {
+	case "text":
 		if err := packageText.Execute(info, &buf); err != nil {
 			log.Stderrf("packageText.Execute: %s", err);
 		}
 		serveText(c, buf.Bytes());
 		return;
+
+	case "json":
+		serveJson(c, newJsonPageInfo(info));
+		return;
 	}
 
 	if err := packageHtml.Execute(info, &buf); err != nil {
@@ -890,6 +1190,11 @@ func search(c *http.Conn, r *http.Request) {
 		result.Legend = &infoClasses;
 	}
 
+	if r.FormValue("f") == "json" {
+		serveJson(c, &result);
+		return;
+	}
+
 	var buf bytes.Buffer;
 	if err := searchHtml.Execute(result, &buf); err != nil {
 		log.Stderrf("searchHtml.Execute: %s", err);
@@ -918,6 +1223,7 @@ var (
 func registerPublicHandlers(mux *http.ServeMux) {
 	mux.Handle(cmdHandler.pattern, &cmdHandler);
 	mux.Handle(pkgHandler.pattern, &pkgHandler);
+	mux.Handle("/doc/codewalk/", http.HandlerFunc(serveCodewalk));
 	mux.Handle("/search", http.HandlerFunc(search));
 	mux.Handle("/", http.HandlerFunc(serveFile));
 }
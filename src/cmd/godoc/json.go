@@ -0,0 +1,165 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the ?f=json rendering of PageInfo and
+// SearchResult, for editors and other tooling that want to consume
+// godoc's output programmatically instead of scraping HTML.
+
+package main
+
+import (
+	"bytes";
+	"go/ast";
+	"go/doc";
+	"http";
+	"json";
+	"log";
+	"strings";
+)
+
+
+// jsonDecl is a JSON-friendly stand-in for a single documented
+// declaration. Since ast.Decl values don't round-trip through JSON
+// (they carry unexported fields and file positions), Decl holds the
+// gofmt'd source text instead, and Doc holds the doc comment
+// rendered to HTML.
+type jsonDecl struct {
+	Name	string;
+	Kind	string;	// "const", "var", "type", or "func"
+	Decl	string;
+	Doc	string;
+}
+
+
+// jsonDirNode mirrors Directory, dropping the Path field (the
+// directory's path relative to goroot) in favor of a tree the
+// client can walk by Name alone.
+type jsonDirNode struct {
+	Name		string;
+	Synopsis	string;
+	Dirs		[]*jsonDirNode;
+}
+
+
+// jsonPackage is the JSON-friendly counterpart of *doc.PackageDoc.
+type jsonPackage struct {
+	PackageName	string;
+	ImportPath	string;
+	Doc		string;
+	Consts		[]jsonDecl;
+	Types		[]jsonDecl;
+	Vars		[]jsonDecl;
+	Funcs		[]jsonDecl;
+}
+
+
+// jsonPageInfo is the JSON-friendly counterpart of PageInfo.
+type jsonPageInfo struct {
+	Package	*jsonPackage;	// nil if no package found
+	Dirs	*jsonDirNode;	// nil if no directory information found
+	IsPkg	bool;
+}
+
+
+// declText renders decl via the existing printer machinery (the
+// same writeNode used for HTML source listings) into plain text.
+func declText(decl ast.Decl) string {
+	var buf bytes.Buffer;
+	writeNode(&buf, decl, false, &defaultStyler);
+	return buf.String();
+}
+
+
+// commentHtml renders a doc comment the same way package.html does,
+// via doc.ToHtml, so JSON and HTML clients see the same markup.
+func commentHtml(doctext string) string {
+	var buf bytes.Buffer;
+	doc.ToHtml(&buf, strings.Bytes(doctext));
+	return buf.String();
+}
+
+
+func valueDeclName(d *ast.GenDecl) string {
+	for _, spec := range d.Specs {
+		if v, ok := spec.(*ast.ValueSpec); ok && len(v.Names) > 0 {
+			return v.Names[0].Value;
+		}
+	}
+	return "";
+}
+
+
+func newJsonDecl(kind, name, doctext string, decl ast.Decl) jsonDecl {
+	return jsonDecl{
+		Name: name,
+		Kind: kind,
+		Decl: declText(decl),
+		Doc: commentHtml(doctext),
+	};
+}
+
+
+func newJsonPackage(pdoc *doc.PackageDoc) *jsonPackage {
+	if pdoc == nil {
+		return nil;
+	}
+
+	p := &jsonPackage{
+		PackageName: pdoc.PackageName,
+		ImportPath: pdoc.ImportPath,
+		Doc: commentHtml(pdoc.Doc),
+	};
+
+	for _, d := range pdoc.Consts {
+		p.Consts = append(p.Consts, newJsonDecl("const", valueDeclName(d.Decl), d.Doc, d.Decl));
+	}
+	for _, d := range pdoc.Vars {
+		p.Vars = append(p.Vars, newJsonDecl("var", valueDeclName(d.Decl), d.Doc, d.Decl));
+	}
+	for _, d := range pdoc.Types {
+		p.Types = append(p.Types, newJsonDecl("type", d.Type.Name.Value, d.Doc, d.Decl));
+	}
+	for _, d := range pdoc.Funcs {
+		p.Funcs = append(p.Funcs, newJsonDecl("func", d.Name, d.Doc, d.Decl));
+	}
+
+	return p;
+}
+
+
+func newJsonDirNode(dir *Directory) *jsonDirNode {
+	if dir == nil {
+		return nil;
+	}
+	n := &jsonDirNode{Name: dir.Name, Synopsis: dir.Text};
+	for _, d := range dir.Dirs {
+		n.Dirs = append(n.Dirs, newJsonDirNode(d));
+	}
+	return n;
+}
+
+
+// newJsonPageInfo converts a PageInfo into its JSON-friendly form.
+func newJsonPageInfo(info PageInfo) *jsonPageInfo {
+	return &jsonPageInfo{
+		Package: newJsonPackage(info.PDoc),
+		Dirs: newJsonDirNode(info.Dirs),
+		IsPkg: info.IsPkg,
+	};
+}
+
+
+// serveJson marshals v as JSON and writes it to c.
+func serveJson(c *http.Conn, v interface{}) {
+	b, err := json.Marshal(v);
+	if err != nil {
+		log.Stderrf("json.Marshal: %s", err);
+		c.WriteHeader(http.StatusInternalServerError);
+		return;
+	}
+	c.SetHeader("content-type", "application/json; charset=utf-8");
+	c.Write(b);
+}
+
+
@@ -0,0 +1,240 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package time
+
+import (
+	"os";
+	"strconv";
+)
+
+
+// A Duration represents the elapsed time between two instants as an
+// int64 nanosecond count. The representation limits the largest
+// representable duration to approximately 290 years.
+type Duration int64
+
+const (
+	Nanosecond	Duration	= 1;
+	Microsecond			= 1000 * Nanosecond;
+	Millisecond			= 1000 * Microsecond;
+	Second				= 1000 * Millisecond;
+	Minute				= 60 * Second;
+	Hour				= 60 * Minute;
+)
+
+
+// Nanoseconds returns the duration as an integer nanosecond count.
+func (d Duration) Nanoseconds() int64 {
+	return int64(d);
+}
+
+
+// Seconds returns the duration as a floating point number of seconds.
+func (d Duration) Seconds() float64 {
+	return float64(d) / float64(Second);
+}
+
+
+// fmtFrac formats the fraction of v/10**prec (e.g., ".12300") into
+// buf, dropping trailing zeros and the period itself if the
+// fraction is zero; it returns the remaining integer part of v and
+// the number of bytes written at the end of buf.
+func fmtFrac(buf []byte, v int64, prec int) (nv int64, nw int) {
+	print := false;
+	w := len(buf);
+	for i := 0; i < prec; i++ {
+		digit := v % 10;
+		print = print || digit != 0;
+		if print {
+			w--;
+			buf[w] = byte(digit) + '0';
+		}
+		v /= 10;
+	}
+	if print {
+		w--;
+		buf[w] = '.';
+	}
+	return v, len(buf) - w;
+}
+
+
+func fmtInt(buf []byte, v uint64) int {
+	w := len(buf);
+	if v == 0 {
+		w--;
+		buf[w] = '0';
+	} else {
+		for v > 0 {
+			w--;
+			buf[w] = byte(v%10) + '0';
+			v /= 10;
+		}
+	}
+	return len(buf) - w;
+}
+
+
+// String returns a string representation of d in the form
+// "72h3m0.5s", dropping leading zero units, the same way the real
+// time.Duration formats its values. Durations less than a second
+// are formatted with a smaller unit (ms, µs, or ns) for precision.
+func (d Duration) String() string {
+	// largest value is about 2^63 ns == 292 years; 32 bytes is plenty
+	var buf [32]byte;
+	w := len(buf);
+
+	u := uint64(d);
+	neg := d < 0;
+	if neg {
+		u = uint64(-d);
+	}
+
+	if u < uint64(Second) {
+		// special case: use smaller units for sub-second durations
+		var prec int;
+		var unit string;
+		switch {
+		case u == 0:
+			return "0s";
+		case u < uint64(Microsecond):
+			prec, unit = 0, "ns";
+		case u < uint64(Millisecond):
+			prec, unit = 3, "µs";
+		default:
+			prec, unit = 6, "ms";
+		}
+		w -= len(unit);
+		copy(buf[w:len(buf)], unit);
+		w--;
+		var n int;
+		u, n = fmtFrac(buf[0:w+1], int64(u), prec);
+		w = w + 1 - n;
+		w -= fmtInt(buf[0:w], u);
+	} else {
+		w--;
+		buf[w] = 's';
+
+		var n int;
+		var uv int64;
+		uv, n = fmtFrac(buf[0:w], int64(u), 9);
+		u = uint64(uv);
+		w -= n;
+		w -= fmtInt(buf[0:w], u%60);
+		u /= 60;
+
+		// minutes
+		if u > 0 {
+			w--;
+			buf[w] = 'm';
+			w -= fmtInt(buf[0:w], u%60);
+			u /= 60;
+
+			// hours
+			if u > 0 {
+				w--;
+				buf[w] = 'h';
+				w -= fmtInt(buf[0:w], u);
+			}
+		}
+	}
+
+	if neg {
+		w--;
+		buf[w] = '-';
+	}
+
+	return string(buf[w:len(buf)]);
+}
+
+
+// ParseDuration parses a duration string such as "300ms", "1.5s", or
+// "2h45m". A duration string is a possibly signed sequence of
+// decimal numbers, each with an optional fraction and a unit
+// suffix, such as "300ms", "-1.5h" or "2h45m". Valid units are "ns",
+// "us" (or "µs"), "ms", "s", "m", "h".
+func ParseDuration(s string) (Duration, os.Error) {
+	orig := s;
+	neg := false;
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		neg = s[0] == '-';
+		s = s[1:len(s)];
+	}
+	if s == "" {
+		return 0, os.ErrorString("time: invalid duration " + orig);
+	}
+
+	var d int64;
+	for s != "" {
+		var v int64;	// integer part
+		var err os.Error;
+
+		i := 0;
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++;
+		}
+		if i == 0 {
+			return 0, os.ErrorString("time: invalid duration " + orig);
+		}
+		v, err = strconv.Atoi64(s[0:i]);
+		if err != nil {
+			return 0, os.ErrorString("time: invalid duration " + orig);
+		}
+		s = s[i:len(s)];
+
+		f := int64(0);	// fraction, as a value in [0, scale)
+		scale := int64(1);
+		if s != "" && s[0] == '.' {
+			s = s[1:len(s)];
+			start := s;
+			for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+				s = s[1:len(s)];
+			}
+			fracDigits := start[0 : len(start)-len(s)];
+			if fracDigits != "" {
+				f, _ = strconv.Atoi64(fracDigits);
+				for i := 0; i < len(fracDigits); i++ {
+					scale *= 10;
+				}
+			}
+		}
+
+		// consume unit
+		i = 0;
+		for i < len(s) && !('0' <= s[i] && s[i] <= '9') && s[i] != '.' {
+			i++;
+		}
+		if i == 0 {
+			return 0, os.ErrorString("time: missing unit in duration " + orig);
+		}
+		unit := s[0:i];
+		s = s[i:len(s)];
+
+		var scaleUnit int64;
+		switch unit {
+		case "ns":
+			scaleUnit = 1;
+		case "us", "µs":
+			scaleUnit = 1e3;
+		case "ms":
+			scaleUnit = 1e6;
+		case "s":
+			scaleUnit = 1e9;
+		case "m":
+			scaleUnit = 60 * 1e9;
+		case "h":
+			scaleUnit = 60 * 60 * 1e9;
+		default:
+			return 0, os.ErrorString("time: unknown unit " + unit + " in duration " + orig);
+		}
+
+		d += v*scaleUnit + f*scaleUnit/scale;
+	}
+
+	if neg {
+		d = -d;
+	}
+	return Duration(d), nil;
+}
@@ -0,0 +1,590 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package time
+
+import (
+	"os";
+	"strconv";
+)
+
+
+// These are predefined layouts for use in Time.Format and Parse.
+// The reference time used in these layouts is the specific time:
+//
+//	Mon Jan 2 15:04:05 MST 2006
+//
+// which is Unix time 1136239445. Since MST is GMT-0700, the reference
+// time can be thought of as
+//
+//	01/02 03:04:05PM '06 -0700
+//
+// To define your own format, write down what the reference time would
+// look like formatted your way; see the values of the predefined
+// layouts below as examples.
+const (
+	ANSIC		= "Mon Jan _2 15:04:05 2006";
+	UnixDate	= "Mon Jan _2 15:04:05 MST 2006";
+	RFC822		= "02 Jan 06 15:04 MST";
+	RFC822Z		= "02 Jan 06 15:04 -0700";
+	RFC850		= "Monday, 02-Jan-06 15:04:05 MST";
+	RFC1123		= "Mon, 02 Jan 2006 15:04:05 MST";
+	RFC1123Z	= "Mon, 02 Jan 2006 15:04:05 -0700";
+	RFC3339		= "2006-01-02T15:04:05Z07:00";
+	RFC3339Nano	= "2006-01-02T15:04:05.999999999Z07:00";
+	Kitchen		= "3:04PM";
+)
+
+
+// std... constants name the individual chunks nextStdChunk can
+// recognize at the head of a layout string; when two chunks would
+// otherwise overlap (e.g. "2" is a prefix of "2006"), the order of
+// the checks in nextStdChunk decides which one wins.
+const (
+	stdNone	= iota;
+	stdLongMonth;		// "January"
+	stdMonth;		// "Jan"
+	stdNumMonth;		// "1"
+	stdZeroMonth;		// "01"
+	stdLongWeekDay;		// "Monday"
+	stdWeekDay;		// "Mon"
+	stdDay;			// "2"
+	stdUnderDay;		// "_2"
+	stdZeroDay;		// "02"
+	stdHour;		// "15"
+	stdHour12;		// "3"
+	stdZeroHour12;		// "03"
+	stdMinute;		// "4"
+	stdZeroMinute;		// "04"
+	stdSecond;		// "5"
+	stdZeroSecond;		// "05"
+	stdLongYear;		// "2006"
+	stdYear;		// "06"
+	stdPM;			// "PM"
+	stdpm;			// "pm"
+	stdTZ;			// "MST"
+	stdISO8601TZ;		// "Z0700"
+	stdISO8601ColonTZ;	// "Z07:00"
+	stdNumTZ;		// "-0700"
+	stdNumColonTZ;		// "-07:00"
+	stdFracSecond0;		// ".0", ".00", ... (trailing zeros kept)
+	stdFracSecond9;		// ".9", ".99", ... (trailing zeros dropped)
+)
+
+
+type stdChunk struct {
+	code	int;
+	n	int;	// for fractional seconds, number of digits
+}
+
+
+// nextStdChunk finds the first recognized reference-time chunk in
+// layout and returns the literal prefix before it, the chunk itself,
+// and the remainder of the layout after the chunk.
+func nextStdChunk(layout string) (prefix string, chunk stdChunk, suffix string) {
+	for i := 0; i < len(layout); i++ {
+		switch layout[i] {
+		case 'J':	// January, Jan
+			if len(layout) >= i+7 && layout[i:i+7] == "January" {
+				return layout[0:i], stdChunk{stdLongMonth, 0}, layout[i+7:];
+			}
+			if len(layout) >= i+3 && layout[i:i+3] == "Jan" {
+				return layout[0:i], stdChunk{stdMonth, 0}, layout[i+3:];
+			}
+
+		case 'M':	// Monday, Mon, MST
+			if len(layout) >= i+6 && layout[i:i+6] == "Monday" {
+				return layout[0:i], stdChunk{stdLongWeekDay, 0}, layout[i+6:];
+			}
+			if len(layout) >= i+3 && layout[i:i+3] == "Mon" {
+				return layout[0:i], stdChunk{stdWeekDay, 0}, layout[i+3:];
+			}
+			if len(layout) >= i+3 && layout[i:i+3] == "MST" {
+				return layout[0:i], stdChunk{stdTZ, 0}, layout[i+3:];
+			}
+
+		case '0':	// 01, 02, 03, 04, 05, 06
+			if len(layout) >= i+2 && '1' <= layout[i+1] && layout[i+1] <= '6' {
+				code := [7]int{0, stdZeroMonth, stdZeroDay, stdZeroHour12, stdZeroMinute, stdZeroSecond, stdYear}[layout[i+1]-'0'];
+				return layout[0:i], stdChunk{code, 0}, layout[i+2:];
+			}
+
+		case '1':	// 15, 1 (month)
+			if len(layout) >= i+2 && layout[i+1] == '5' {
+				return layout[0:i], stdChunk{stdHour, 0}, layout[i+2:];
+			}
+			return layout[0:i], stdChunk{stdNumMonth, 0}, layout[i+1:];
+
+		case '2':	// 2006, 2 (day)
+			if len(layout) >= i+4 && layout[i:i+4] == "2006" {
+				return layout[0:i], stdChunk{stdLongYear, 0}, layout[i+4:];
+			}
+			return layout[0:i], stdChunk{stdDay, 0}, layout[i+1:];
+
+		case '_':	// _2
+			if len(layout) >= i+2 && layout[i+1] == '2' {
+				return layout[0:i], stdChunk{stdUnderDay, 0}, layout[i+2:];
+			}
+
+		case '3':
+			return layout[0:i], stdChunk{stdHour12, 0}, layout[i+1:];
+
+		case '4':
+			return layout[0:i], stdChunk{stdMinute, 0}, layout[i+1:];
+
+		case '5':
+			return layout[0:i], stdChunk{stdSecond, 0}, layout[i+1:];
+
+		case 'P':	// PM
+			if len(layout) >= i+2 && layout[i+1] == 'M' {
+				return layout[0:i], stdChunk{stdPM, 0}, layout[i+2:];
+			}
+
+		case 'p':	// pm
+			if len(layout) >= i+2 && layout[i+1] == 'm' {
+				return layout[0:i], stdChunk{stdpm, 0}, layout[i+2:];
+			}
+
+		case '-':	// -0700, -07:00
+			if len(layout) >= i+5 && layout[i:i+5] == "-0700" {
+				return layout[0:i], stdChunk{stdNumTZ, 0}, layout[i+5:];
+			}
+			if len(layout) >= i+6 && layout[i:i+6] == "-07:00" {
+				return layout[0:i], stdChunk{stdNumColonTZ, 0}, layout[i+6:];
+			}
+
+		case 'Z':	// Z0700, Z07:00
+			if len(layout) >= i+5 && layout[i:i+5] == "Z0700" {
+				return layout[0:i], stdChunk{stdISO8601TZ, 0}, layout[i+5:];
+			}
+			if len(layout) >= i+6 && layout[i:i+6] == "Z07:00" {
+				return layout[0:i], stdChunk{stdISO8601ColonTZ, 0}, layout[i+6:];
+			}
+
+		case '.':	// .000, .999 - fractional seconds
+			if len(layout) >= i+2 && (layout[i+1] == '0' || layout[i+1] == '9') {
+				ch := layout[i+1];
+				j := i + 1;
+				for j < len(layout) && layout[j] == ch {
+					j++;
+				}
+				code := stdFracSecond9;
+				if ch == '0' {
+					code = stdFracSecond0;
+				}
+				return layout[0:i], stdChunk{code, j - (i + 1)}, layout[j:];
+			}
+		}
+	}
+	return layout, stdChunk{stdNone, 0}, "";
+}
+
+
+func pad(i int, width int) string {
+	s := strconv.Itoa(i);
+	for len(s) < width {
+		s = "0" + s;
+	}
+	return s;
+}
+
+
+func padSpace(i int, width int) string {
+	s := strconv.Itoa(i);
+	for len(s) < width {
+		s = " " + s;
+	}
+	return s;
+}
+
+
+// Format returns a textual representation of t in the form defined
+// by layout. Layout is itself a time value, formatted as it would
+// be if it were the reference time
+//
+//	Mon Jan 2 15:04:05 MST 2006
+//
+// The same reference-time pattern, wherever it appears in layout, is
+// replaced with the corresponding value of t.
+func (t Time) Format(layout string) string {
+	var buf []byte;
+	hour := t.Hour();
+	zoneName, offset := t.Location().lookup(t.sec);
+
+	for {
+		var prefix string;
+		var chunk stdChunk;
+		prefix, chunk, layout = nextStdChunk(layout);
+		buf = append(buf, prefix...);
+		if chunk.code == stdNone {
+			break;
+		}
+
+		switch chunk.code {
+		case stdLongMonth:
+			buf = append(buf, monthName(t.Month())...);
+		case stdMonth:
+			buf = append(buf, monthName(t.Month())[0:3]...);
+		case stdNumMonth:
+			buf = append(buf, strconv.Itoa(t.Month())...);
+		case stdZeroMonth:
+			buf = append(buf, pad(t.Month(), 2)...);
+		case stdLongWeekDay:
+			buf = append(buf, t.Weekday().String()...);
+		case stdWeekDay:
+			buf = append(buf, t.Weekday().String()[0:3]...);
+		case stdDay:
+			buf = append(buf, strconv.Itoa(t.Day())...);
+		case stdUnderDay:
+			buf = append(buf, padSpace(t.Day(), 2)...);
+		case stdZeroDay:
+			buf = append(buf, pad(t.Day(), 2)...);
+		case stdHour:
+			buf = append(buf, pad(hour, 2)...);
+		case stdHour12:
+			buf = append(buf, strconv.Itoa(hour12(hour))...);
+		case stdZeroHour12:
+			buf = append(buf, pad(hour12(hour), 2)...);
+		case stdMinute:
+			buf = append(buf, strconv.Itoa(t.Minute())...);
+		case stdZeroMinute:
+			buf = append(buf, pad(t.Minute(), 2)...);
+		case stdSecond:
+			buf = append(buf, strconv.Itoa(t.Second())...);
+		case stdZeroSecond:
+			buf = append(buf, pad(t.Second(), 2)...);
+		case stdLongYear:
+			buf = append(buf, strconv.Itoa64(t.Year())...);
+		case stdYear:
+			y := t.Year() % 100;
+			if y < 0 {
+				y += 100;
+			}
+			buf = append(buf, pad(int(y), 2)...);
+		case stdPM:
+			if hour >= 12 {
+				buf = append(buf, "PM"...);
+			} else {
+				buf = append(buf, "AM"...);
+			}
+		case stdpm:
+			if hour >= 12 {
+				buf = append(buf, "pm"...);
+			} else {
+				buf = append(buf, "am"...);
+			}
+		case stdTZ:
+			buf = append(buf, zoneName...);
+		case stdISO8601TZ, stdISO8601ColonTZ, stdNumTZ, stdNumColonTZ:
+			if offset == 0 && (chunk.code == stdISO8601TZ || chunk.code == stdISO8601ColonTZ) {
+				buf = append(buf, 'Z');
+				break;
+			}
+			zone := offset / 60;	// convert to minutes
+			sign := '+';
+			if zone < 0 {
+				sign, zone = '-', -zone;
+			}
+			buf = append(buf, byte(sign));
+			buf = append(buf, pad(zone/60, 2)...);
+			if chunk.code == stdISO8601ColonTZ || chunk.code == stdNumColonTZ {
+				buf = append(buf, ':');
+			}
+			buf = append(buf, pad(zone%60, 2)...);
+		case stdFracSecond0:
+			buf = append(buf, formatFrac0(t.nsec, chunk.n)...);
+		case stdFracSecond9:
+			buf = append(buf, formatFrac9(t.nsec, chunk.n)...);
+		}
+	}
+
+	return string(buf);
+}
+
+
+func hour12(hour int) int {
+	h := hour % 12;
+	if h == 0 {
+		h = 12;
+	}
+	return h;
+}
+
+
+func formatFrac0(nsec int32, n int) string {
+	s := pad(int(nsec), 9);
+	if n > len(s) {
+		n = len(s);
+	}
+	return "." + s[0:n];
+}
+
+
+func formatFrac9(nsec int32, n int) string {
+	s := pad(int(nsec), 9);
+	if n > len(s) {
+		n = len(s);
+	}
+	s = s[0:n];
+	for len(s) > 0 && s[len(s)-1] == '0' {
+		s = s[0 : len(s)-1];
+	}
+	if s == "" {
+		return "";
+	}
+	return "." + s;
+}
+
+
+// ----------------------------------------------------------------------------
+// Parse
+
+// A ParseError describes a problem parsing a time string.
+type ParseError struct {
+	Layout	string;
+	Value	string;
+	Message	string;
+}
+
+func (e *ParseError) String() string {
+	return "parsing time " + strconv.Quote(e.Value) + " as " + strconv.Quote(e.Layout) + ": " + e.Message;
+}
+
+
+func skip(value, prefix string) (string, os.Error) {
+	if len(value) < len(prefix) || value[0:len(prefix)] != prefix {
+		return value, os.NewError("time: layout mismatch, expected " + strconv.Quote(prefix));
+	}
+	return value[len(prefix):len(value)], nil;
+}
+
+
+// getnum parses a one- or two-digit decimal number from the front of
+// value. If fixed is true exactly two digits are required (e.g. "04"),
+// otherwise a single leading digit is accepted (e.g. "4" or "04").
+func getnum(value string, fixed bool) (int, string, os.Error) {
+	if len(value) < 1 || value[0] < '0' || value[0] > '9' {
+		return 0, value, os.NewError("time: bad numeric field " + strconv.Quote(value));
+	}
+	if len(value) < 2 || value[1] < '0' || value[1] > '9' {
+		if fixed {
+			return 0, value, os.NewError("time: bad numeric field " + strconv.Quote(value));
+		}
+		n, err := strconv.Atoi(value[0:1]);
+		return n, value[1:len(value)], err;
+	}
+	n, err := strconv.Atoi(value[0:2]);
+	return n, value[2:len(value)], err;
+}
+
+
+func lookupName(value string, names []string) (int, string, bool) {
+	for i, name := range names {
+		if len(value) >= len(name) && value[0:len(name)] == name {
+			return i, value[len(name):len(value)], true;
+		}
+	}
+	return 0, value, false;
+}
+
+
+// Parse parses a formatted string and returns the time value it
+// represents, using layout to interpret the fields in value. Parse
+// recognizes the same reference-time scheme as Format.
+func Parse(layout, value string) (Time, os.Error) {
+	var year, month, day, hour, minute, second, nsec int;
+	month = 1;
+	day = 1;
+	pmSet, pmVal := false, false;
+	var zoneName string;
+	var zoneOffset int;
+	zoneOffsetSet := false;
+
+	for {
+		var prefix string;
+		var chunk stdChunk;
+		var err os.Error;
+		prefix, chunk, layout = nextStdChunk(layout);
+
+		value, err = skip(value, prefix);
+		if err != nil {
+			return Time{}, &ParseError{layout, value, err.String()};
+		}
+		if chunk.code == stdNone {
+			break;
+		}
+
+		switch chunk.code {
+		case stdLongMonth:
+			var ok bool;
+			month, value, ok = lookupName(value, monthNames);
+			month++;
+			if !ok {
+				return Time{}, &ParseError{layout, value, "cannot parse month"};
+			}
+		case stdMonth:
+			var ok bool;
+			var i int;
+			i, value, ok = lookupName(value, shortMonthNames());
+			month = i + 1;
+			if !ok {
+				return Time{}, &ParseError{layout, value, "cannot parse month"};
+			}
+		case stdNumMonth, stdZeroMonth:
+			month, value, err = getnum(value, chunk.code == stdZeroMonth);
+		case stdLongWeekDay:
+			_, value, _ = lookupName(value, weekdayNames);
+		case stdWeekDay:
+			_, value, _ = lookupName(value, shortWeekdayNames());
+		case stdDay, stdUnderDay, stdZeroDay:
+			if chunk.code == stdUnderDay && len(value) > 0 && value[0] == ' ' {
+				value = value[1:len(value)];
+			}
+			day, value, err = getnum(value, chunk.code == stdZeroDay);
+		case stdHour:
+			hour, value, err = getnum(value, false);
+		case stdHour12, stdZeroHour12:
+			hour, value, err = getnum(value, chunk.code == stdZeroHour12);
+		case stdMinute, stdZeroMinute:
+			minute, value, err = getnum(value, chunk.code == stdZeroMinute);
+		case stdSecond, stdZeroSecond:
+			second, value, err = getnum(value, chunk.code == stdZeroSecond);
+		case stdLongYear:
+			if len(value) < 4 {
+				return Time{}, &ParseError{layout, value, "cannot parse year"};
+			}
+			year, err = strconv.Atoi(value[0:4]);
+			value = value[4:len(value)];
+		case stdYear:
+			year, value, err = getnum(value, false);
+			if year >= 69 {	// consistent with the Unix 2-digit-year convention
+				year += 1900;
+			} else {
+				year += 2000;
+			}
+		case stdPM:
+			if len(value) < 2 {
+				return Time{}, &ParseError{layout, value, "cannot parse AM/PM"};
+			}
+			pmSet, pmVal = true, value[0:2] == "PM";
+			value = value[2:len(value)];
+		case stdpm:
+			if len(value) < 2 {
+				return Time{}, &ParseError{layout, value, "cannot parse am/pm"};
+			}
+			pmSet, pmVal = true, value[0:2] == "pm";
+			value = value[2:len(value)];
+		case stdTZ:
+			// Accept a run of non-space, non-digit characters as a
+			// zone abbreviation (e.g. "MST", "PST"); the abbreviation
+			// is resolved against Local, since that is the only
+			// Location whose zone names are known without also
+			// knowing which Location to look it up in.
+			i := 0;
+			for i < len(value) && value[i] != ' ' && (value[i] < '0' || value[i] > '9') {
+				i++;
+			}
+			zoneName = value[0:i];
+			value = value[i:len(value)];
+		case stdISO8601TZ, stdISO8601ColonTZ, stdNumTZ, stdNumColonTZ:
+			if len(value) > 0 && value[0] == 'Z' {
+				value = value[1:len(value)];
+				zoneOffset, zoneOffsetSet = 0, true;
+				break;
+			}
+			n := 5;
+			if chunk.code == stdISO8601ColonTZ || chunk.code == stdNumColonTZ {
+				n = 6;
+			}
+			if len(value) < n || (value[0] != '+' && value[0] != '-') {
+				return Time{}, &ParseError{layout, value, "cannot parse zone offset"};
+			}
+			sign := value[0];
+			hh, err1 := strconv.Atoi(value[1:3]);
+			var mm int;
+			var err2 os.Error;
+			if n == 6 {
+				mm, err2 = strconv.Atoi(value[4:6]);
+			} else {
+				mm, err2 = strconv.Atoi(value[3:5]);
+			}
+			if err1 != nil || err2 != nil {
+				return Time{}, &ParseError{layout, value, "cannot parse zone offset"};
+			}
+			zoneOffset = hh*3600 + mm*60;
+			if sign == '-' {
+				zoneOffset = -zoneOffset;
+			}
+			zoneOffsetSet = true;
+			value = value[n:len(value)];
+		case stdFracSecond0, stdFracSecond9:
+			if len(value) > 0 && value[0] == '.' {
+				value = value[1:len(value)];
+				i := 0;
+				for i < len(value) && value[i] >= '0' && value[i] <= '9' {
+					i++;
+				}
+				digits := value[0:i];
+				for len(digits) < 9 {
+					digits += "0";
+				}
+				n, _ := strconv.Atoi(digits[0:9]);
+				nsec = n;
+				value = value[i:len(value)];
+			}
+		}
+
+		if err != nil {
+			return Time{}, &ParseError{layout, value, err.String()};
+		}
+	}
+
+	if pmSet {
+		if pmVal && hour < 12 {
+			hour += 12;
+		}
+		if !pmVal && hour == 12 {
+			hour = 0;
+		}
+	}
+
+	days := daysFromCivil(int64(year), month, day);
+	sec := days*86400 + int64(hour)*3600 + int64(minute)*60 + int64(second);
+
+	if zoneOffsetSet {
+		// sec is the local wall clock; convert to the absolute
+		// instant by subtracting the parsed offset, then attach a
+		// fixed-offset Location so Format round-trips the same text.
+		if zoneOffset == 0 {
+			return Time{sec, int32(nsec), UTC}, nil;
+		}
+		return Time{sec - int64(zoneOffset), int32(nsec), FixedZone("", zoneOffset)}, nil;
+	}
+	if zoneName != "" {
+		// An abbreviation alone (no numeric offset) is ambiguous in
+		// general, but is resolved against Local, matching its
+		// offset at the parsed wall-clock time.
+		_, offset := Local.lookup(sec);
+		return Time{sec - int64(offset), int32(nsec), Local}, nil;
+	}
+	return Time{sec, int32(nsec), UTC}, nil;
+}
+
+
+func shortMonthNames() []string {
+	names := make([]string, len(monthNames));
+	for i, n := range monthNames {
+		names[i] = n[0:3];
+	}
+	return names;
+}
+
+
+func shortWeekdayNames() []string {
+	names := make([]string, len(weekdayNames));
+	for i, n := range weekdayNames {
+		names[i] = n[0:3];
+	}
+	return names;
+}
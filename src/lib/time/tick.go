@@ -0,0 +1,170 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package time
+
+// A Ticker holds a channel that delivers `ticks' of a clock at intervals.
+type Ticker struct {
+	C	<-chan int64;	// the channel on which the ticks are delivered
+	c	chan<- int64;	// the same channel, but the end the ticker writes to
+	d	Duration;
+	shutdown	chan bool;	// receives a value from Stop to stop the ticker
+}
+
+
+// Stop turns off a ticker. After Stop, no more ticks will be sent.
+// Stop does not close the channel, to avoid a read from the channel
+// succeeding incorrectly.
+func (t *Ticker) Stop() {
+	t.shutdown <- true;
+}
+
+
+func (t *Ticker) tick() {
+	now := Nanoseconds();
+	when := now;
+	for {
+		when += int64(t.d);
+		if when < now {	// overflow or interval too small; give up
+			break;
+		}
+		Sleep(when - now);
+		now = Nanoseconds();
+
+		select {
+		case <-t.shutdown:
+			return;
+		default:
+		}
+
+		select {
+		case t.c <- now:
+		default:	// a slow reader must not block the ticker
+		}
+	}
+}
+
+
+// NewTicker returns a new Ticker containing a channel that will send
+// the time, in nanoseconds, every d. The ticker will adjust the time
+// interval or drop ticks to make up for slow receivers. It never
+// stops on its own; the caller must call Stop to release the
+// associated resources.
+func NewTicker(d Duration) *Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker");
+	}
+	c := make(chan int64, 1);	// buffered so tick() never blocks on a slow reader
+	t := &Ticker{c, c, d, make(chan bool, 1)};
+	go t.tick();
+	return t;
+}
+
+
+// Tick is a convenience wrapper for NewTicker, providing access to
+// the ticking channel only. Useful for callers that never need to
+// shut down the ticker - but since the sender goroutine then runs
+// for the lifetime of the process, Tick should not be used when only
+// a finite number of ticks are needed; use NewTicker and Stop
+// instead.
+func Tick(d Duration) <-chan int64 {
+	if d <= 0 {
+		return nil;
+	}
+	return NewTicker(d).C;
+}
+
+
+// A Timer is a single event, delivered once on C after the given
+// interval has elapsed, or by invoking a function if created with
+// AfterFunc.
+type Timer struct {
+	C	<-chan int64;
+	c	chan<- int64;
+	shutdown	chan bool;
+}
+
+
+// Stop prevents the Timer from firing. It does not close the
+// channel, to avoid a read succeeding incorrectly. Stop has no
+// effect if the timer has already fired or been stopped.
+func (t *Timer) Stop() {
+	select {
+	case t.shutdown <- true:
+	default:	// already stopped
+	}
+}
+
+
+func (t *Timer) wait(d Duration, f func()) {
+	select {
+	case <-time_after(d):
+		if f != nil {
+			f();
+		} else {
+			t.c <- Nanoseconds();
+		}
+	case <-t.shutdown:
+	}
+}
+
+
+// time_after waits for d to elapse and then sends the current time
+// on the returned channel; it is the one-shot building block shared
+// by After and AfterFunc.
+func time_after(d Duration) <-chan int64 {
+	c := make(chan int64, 1);
+	go func() {
+		Sleep(int64(d));
+		c <- Nanoseconds();
+	}();
+	return c;
+}
+
+
+// After returns a channel on which the current time (in nanoseconds)
+// will be sent once, after d has elapsed.
+func After(d Duration) <-chan int64 {
+	return time_after(d);
+}
+
+
+// AfterFunc waits for d to elapse and then calls f in its own
+// goroutine. It returns a Timer that can be used to cancel the call
+// using its Stop method, as long as f has not yet been started.
+func AfterFunc(d Duration, f func()) *Timer {
+	c := make(chan int64, 1);
+	t := &Timer{c, c, make(chan bool, 1)};
+	go t.wait(d, f);
+	return t;
+}
+
+
+// ----------------------------------------------------------------------------
+// Deprecated int64-nanosecond overloads, kept for one release while
+// callers migrate to the Duration-typed entry points above.
+
+// TickNS is like Tick but takes a raw int64 nanosecond count.
+// Deprecated: use Tick.
+func TickNS(ns int64) <-chan int64 {
+	return Tick(Duration(ns));
+}
+
+// NewTickerNS is like NewTicker but takes a raw int64 nanosecond count.
+// Deprecated: use NewTicker.
+func NewTickerNS(ns int64) *Ticker {
+	return NewTicker(Duration(ns));
+}
+
+// AfterNS is like After but takes a raw int64 nanosecond count.
+// Deprecated: use After.
+func AfterNS(ns int64) <-chan int64 {
+	return After(Duration(ns));
+}
+
+// AfterFuncNS is like AfterFunc but takes a raw int64 nanosecond count.
+// Deprecated: use AfterFunc.
+func AfterFuncNS(ns int64, f func()) *Timer {
+	return AfterFunc(Duration(ns), f);
+}
@@ -14,10 +14,11 @@ func TestTick(t *testing.T) {
 		Delta = 100*1e6;
 		Count = 10;
 	);
-	c := Tick(Delta);
+	ticker := NewTicker(Delta);
+	defer ticker.Stop();
 	t0 := Nanoseconds();
 	for i := 0; i < Count; i++ {
-		<-c;
+		<-ticker.C;
 	}
 	t1 := Nanoseconds();
 	ns := t1 - t0;
@@ -0,0 +1,290 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package time
+
+// A Time represents an instant in time with nanosecond precision,
+// associated with a Location for the purpose of presenting it (via
+// Format, or the calendar accessors below) in a particular time zone.
+//
+// Time values can be compared with Equal, Before, and After, and are
+// comparable with == if (and only if) they share the same Location
+// pointer; use Equal for location-independent comparisons.
+type Time struct {
+	sec	int64;	// seconds since January 1, 1970 UTC
+	nsec	int32;	// nanoseconds within the second, [0, 999999999]
+	loc	*Location;
+}
+
+
+// Now returns the current time.
+func Now() Time {
+	ns := Nanoseconds();
+	return Unix(ns/1e9, ns%1e9).In(Local);
+}
+
+
+// Unix returns the Time corresponding to the given Unix time, sec
+// seconds and nsec nanoseconds since January 1, 1970 UTC. It is
+// valid to pass nsec outside the range [0, 999999999); it will be
+// normalized during the conversion.
+func Unix(sec, nsec int64) Time {
+	sec += nsec / 1e9;
+	nsec %= 1e9;
+	if nsec < 0 {
+		nsec += 1e9;
+		sec--;
+	}
+	return Time{sec, int32(nsec), UTC};
+}
+
+
+// In returns a Time representing the same instant but with the
+// calendar fields interpreted in loc.
+func (t Time) In(loc *Location) Time {
+	t.loc = loc;
+	return t;
+}
+
+
+// Location returns the location associated with t.
+func (t Time) Location() *Location {
+	if t.loc == nil {
+		return UTC;
+	}
+	return t.loc;
+}
+
+
+// Unix returns t as a Unix time, the number of seconds elapsed since
+// January 1, 1970 UTC.
+func (t Time) Unix() int64 {
+	return t.sec;
+}
+
+
+// UnixNano returns t as a Unix time, the number of nanoseconds
+// elapsed since January 1, 1970 UTC.
+func (t Time) UnixNano() int64 {
+	return t.sec*1e9 + int64(t.nsec);
+}
+
+
+// ----------------------------------------------------------------------------
+// Arithmetic and comparison
+
+// Add returns the time t+d.
+func (t Time) Add(d Duration) Time {
+	return Time{t.sec + int64(d)/1e9, 0, t.loc}.addNanoseconds(t.nsec + int32(int64(d)%1e9));
+}
+
+func (t Time) addNanoseconds(nsec int32) Time {
+	sec := t.sec;
+	for nsec >= 1e9 {
+		nsec -= 1e9;
+		sec++;
+	}
+	for nsec < 0 {
+		nsec += 1e9;
+		sec--;
+	}
+	return Time{sec, nsec, t.loc};
+}
+
+
+// Sub returns the duration t-u.
+func (t Time) Sub(u Time) Duration {
+	d := Duration(t.sec-u.sec) * Second;
+	d += Duration(t.nsec - u.nsec);
+	return d;
+}
+
+
+// Before reports whether the instant t is before u.
+func (t Time) Before(u Time) bool {
+	return t.sec < u.sec || t.sec == u.sec && t.nsec < u.nsec;
+}
+
+
+// After reports whether the instant t is after u.
+func (t Time) After(u Time) bool {
+	return t.sec > u.sec || t.sec == u.sec && t.nsec > u.nsec;
+}
+
+
+// Equal reports whether t and u represent the same instant in time;
+// unlike ==, it is independent of the Locations associated with t and u.
+func (t Time) Equal(u Time) bool {
+	return t.sec == u.sec && t.nsec == u.nsec;
+}
+
+
+// ----------------------------------------------------------------------------
+// Calendar math
+//
+// The conversion between a day count (relative to the Unix epoch)
+// and a (year, month, day) triple, and back, follows Howard
+// Hinnant's well known constant-time algorithm for the (proleptic)
+// Gregorian calendar.
+
+// A Weekday specifies a day of the week (Sunday = 0, ...).
+type Weekday int
+
+const (
+	Sunday	Weekday	= iota;
+	Monday;
+	Tuesday;
+	Wednesday;
+	Thursday;
+	Friday;
+	Saturday;
+)
+
+var weekdayNames = []string{
+	"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday",
+}
+
+func (d Weekday) String() string	{ return weekdayNames[d] }
+
+
+var monthNames = []string{
+	"January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December",
+}
+
+func monthName(m int) string	{ return monthNames[m-1] }
+
+
+func floorDiv(a, b int64) int64 {
+	q := a / b;
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--;
+	}
+	return q;
+}
+
+func floorMod(a, b int64) int64 {
+	return a - floorDiv(a, b)*b;
+}
+
+
+// civilFromDays converts z, a day count relative to the Unix epoch
+// (1970-01-01 == 0), into a (year, month, day) triple.
+func civilFromDays(z int64) (year int64, month, day int) {
+	z += 719468;	// shift epoch to 0000-03-01
+	era := floorDiv(z, 146097);
+	doe := z - era*146097;	// [0, 146096]
+	yoe := (doe - doe/1460 + doe/36524 - doe/146096) / 365;	// [0, 399]
+	y := yoe + era*400;
+	doy := doe - (365*yoe + yoe/4 - yoe/100);	// [0, 365]
+	mp := (5*doy + 2) / 153;	// [0, 11]
+	d := doy - (153*mp+2)/5 + 1;	// [1, 31]
+	var m int64;
+	if mp < 10 {
+		m = mp + 3;
+	} else {
+		m = mp - 9;
+	}
+	if m <= 2 {
+		y++;
+	}
+	return y, int(m), int(d);
+}
+
+
+// daysFromCivil is the inverse of civilFromDays.
+func daysFromCivil(y int64, m, d int) int64 {
+	if m <= 2 {
+		y--;
+	}
+	era := floorDiv(y, 400);
+	yoe := y - era*400;	// [0, 399]
+	var mp int64;
+	if m > 2 {
+		mp = int64(m) - 3;
+	} else {
+		mp = int64(m) + 9;
+	}
+	doy := (153*mp+2)/5 + int64(d) - 1;	// [0, 365]
+	doe := yoe*365 + yoe/4 - yoe/100 + doy;	// [0, 146096]
+	return era*146097 + doe - 719468;
+}
+
+
+// date returns the year, month, day, and time-of-day (in seconds
+// since local midnight) for t, in t's Location.
+func (t Time) date() (year int64, month, day int, secsOfDay int64) {
+	_, offset := t.Location().lookup(t.sec);
+	local := t.sec + int64(offset);
+	days := floorDiv(local, 86400);
+	secsOfDay = local - days*86400;
+	year, month, day = civilFromDays(days);
+	return;
+}
+
+
+// Year returns the year in which t occurs.
+func (t Time) Year() int64 {
+	y, _, _, _ := t.date();
+	return y;
+}
+
+
+// Month returns the month of the year specified by t (January = 1).
+func (t Time) Month() int {
+	_, m, _, _ := t.date();
+	return m;
+}
+
+
+// Day returns the day of the month specified by t.
+func (t Time) Day() int {
+	_, _, d, _ := t.date();
+	return d;
+}
+
+
+// Hour returns the hour within the day specified by t, in [0, 23].
+func (t Time) Hour() int {
+	_, _, _, s := t.date();
+	return int(s / 3600);
+}
+
+
+// Minute returns the minute offset within the hour specified by t, in [0, 59].
+func (t Time) Minute() int {
+	_, _, _, s := t.date();
+	return int(s / 60 % 60);
+}
+
+
+// Second returns the second offset within the minute specified by t, in [0, 59].
+func (t Time) Second() int {
+	_, _, _, s := t.date();
+	return int(s % 60);
+}
+
+
+// Nanosecond returns the nanosecond offset within the second specified
+// by t, in [0, 999999999].
+func (t Time) Nanosecond() int {
+	return int(t.nsec);
+}
+
+
+// Weekday returns the day of the week specified by t.
+func (t Time) Weekday() Weekday {
+	_, offset := t.Location().lookup(t.sec);
+	days := floorDiv(t.sec+int64(offset), 86400);
+	return Weekday(floorMod(days+4, 7));	// 1970-01-01 (days==0) was a Thursday
+}
+
+
+// YearDay returns the day of the year specified by t, in [1, 366].
+func (t Time) YearDay() int {
+	y, m, d, _ := t.date();
+	days := daysFromCivil(y, m, d);
+	jan1 := daysFromCivil(y, 1, 1);
+	return int(days-jan1) + 1;
+}
@@ -0,0 +1,292 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package time
+
+import (
+	"os";
+	"sync";
+)
+
+// A zone represents a single time zone such as CET or CEST.
+type zone struct {
+	name	string;	// abbreviated name, e.g. "CET"
+	offset	int;	// seconds east of UTC
+	isDST	bool;
+}
+
+// A zoneTrans represents a single transition time; the zone in
+// effect changes to zones[index] at time `when'.
+type zoneTrans struct {
+	when	int64;
+	index	int;
+}
+
+// A Location maps time instants to the zone in use at that
+// instant, as described by a tzdata file loaded via LoadLocation or
+// synthesized by FixedZone. A nil *Location is treated as UTC.
+type Location struct {
+	name	string;
+	zones	[]zone;
+	trans	[]zoneTrans;
+
+	// cacheStart and cacheEnd delimit the zone cacheZone applies
+	// to; it is a fast path for repeated lookups within the same
+	// range, guarded by cacheMu since Locations are shared across
+	// goroutines.
+	cacheMu		sync.Mutex;
+	cacheStart	int64;
+	cacheEnd	int64;
+	cacheZone	*zone;
+}
+
+// UTC represents Universal Coordinated Time (UTC).
+var UTC = &Location{name: "UTC"}
+
+// Local represents the system's local time zone.
+var Local = &localLoc
+
+var localLoc Location
+
+func init() {
+	loc, err := loadLocationFromEnvironment();
+	if err != nil {
+		localLoc.name = "Local";
+		localLoc.zones = []zone{{"UTC", 0, false}};
+		return;
+	}
+	localLoc = *loc;
+	localLoc.name = "Local";
+}
+
+// String returns a descriptive name for the time zone information,
+// corresponding to the name argument of LoadLocation or FixedZone.
+func (l *Location) String() string {
+	if l == nil {
+		return "UTC";
+	}
+	return l.name;
+}
+
+// FixedZone returns a Location that always uses the given zone name
+// and offset (seconds east of UTC), ignoring any tzdata transitions.
+func FixedZone(name string, offset int) *Location {
+	l := &Location{
+		name:	name,
+		zones:	[]zone{{name, offset, false}},
+	};
+	return l;
+}
+
+// lookup returns information about the time zone in use at the
+// instant sec (seconds since January 1, 1970 UTC).
+func (l *Location) lookup(sec int64) (name string, offset int) {
+	if l == nil || len(l.zones) == 0 {
+		return "UTC", 0;
+	}
+
+	l.cacheMu.Lock();
+	if l.cacheZone != nil && l.cacheStart <= sec && sec < l.cacheEnd {
+		z := l.cacheZone;
+		l.cacheMu.Unlock();
+		return z.name, z.offset;
+	}
+	l.cacheMu.Unlock();
+
+	if len(l.trans) == 0 || sec < l.trans[0].when {
+		z := &l.zones[0];
+		return z.name, z.offset;
+	}
+
+	// Binary search for the last transition at or before sec.
+	lo, hi := 0, len(l.trans);
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2;
+		if l.trans[mid].when <= sec {
+			lo = mid;
+		} else {
+			hi = mid;
+		}
+	}
+	tx := l.trans[lo];
+	z := &l.zones[tx.index];
+
+	end := int64(1) << 62;
+	if lo+1 < len(l.trans) {
+		end = l.trans[lo+1].when;
+	}
+	l.cacheMu.Lock();
+	l.cacheStart, l.cacheEnd, l.cacheZone = tx.when, end, z;
+	l.cacheMu.Unlock();
+
+	return z.name, z.offset;
+}
+
+// loadLocationFromEnvironment builds the local Location from the
+// TZ environment variable, falling back to /etc/localtime.
+func loadLocationFromEnvironment() (*Location, os.Error) {
+	if tz := os.Getenv("TZ"); tz != "" {
+		return LoadLocation(tz);
+	}
+	if f, err := openZoneFile("/etc/localtime"); err == nil {
+		defer f.Close();
+		return loadZoneFile(f, "Local");
+	}
+	return nil, os.ErrorString("time: cannot determine local time zone");
+}
+
+// LoadLocation returns the Location with the given name, loaded
+// from the IANA Time Zone database. Names such as "America/New_York"
+// are looked up under $ZONEINFO (if set) and then under
+// /usr/share/zoneinfo. The special name "UTC" always returns UTC.
+func LoadLocation(name string) (*Location, os.Error) {
+	if name == "" || name == "UTC" {
+		return UTC, nil;
+	}
+
+	if zoneinfo := os.Getenv("ZONEINFO"); zoneinfo != "" {
+		if f, err := openZoneFile(zoneinfo + "/" + name); err == nil {
+			defer f.Close();
+			return loadZoneFile(f, name);
+		}
+	}
+
+	if f, err := openZoneFile("/usr/share/zoneinfo/" + name); err == nil {
+		defer f.Close();
+		return loadZoneFile(f, name);
+	}
+
+	return nil, os.ErrorString("time: unknown location " + name);
+}
+
+// openZoneFile opens a tzdata file for reading.
+func openZoneFile(path string) (*os.File, os.Error) {
+	return os.Open(path, os.O_RDONLY, 0);
+}
+
+// ----------------------------------------------------------------------------
+// TZif (binary tzdata) file format, as described by tzfile(5).
+
+// loadZoneFile reads a TZif-formatted file and builds a Location
+// named name from its zone and transition tables.
+func loadZoneFile(f *os.File, name string) (*Location, os.Error) {
+	var buf [64 * 1024]byte;
+	n, err := f.Read(buf[0:]);
+	if err != nil && n == 0 {
+		return nil, err;
+	}
+	return parseTZif(buf[0:n], name);
+}
+
+func parseTZif(data []byte, name string) (*Location, os.Error) {
+	if len(data) < 44 || string(data[0:4]) != "TZif" {
+		return nil, os.ErrorString("time: " + name + " is not a valid tzdata file");
+	}
+
+	p := tzParser{data: data[20:]};	// skip magic, version, and 15 reserved bytes
+
+	isutcnt := p.int32();
+	isstdcnt := p.int32();
+	leapcnt := p.int32();
+	timecnt := p.int32();
+	typecnt := p.int32();
+	charcnt := p.int32();
+
+	transTimes := make([]int64, timecnt);
+	for i := 0; i < timecnt; i++ {
+		transTimes[i] = int64(p.int32());
+	}
+	transTypes := make([]int, timecnt);
+	for i := 0; i < timecnt; i++ {
+		transTypes[i] = int(p.byte());
+	}
+
+	type ttinfo struct {
+		offset	int32;
+		isDST	byte;
+		abbrind	byte;
+	}
+	ttinfos := make([]ttinfo, typecnt);
+	for i := 0; i < typecnt; i++ {
+		ttinfos[i] = ttinfo{p.int32(), p.byte(), p.byte()};
+	}
+
+	abbrev := p.bytes(charcnt);
+
+	// Skip leap-second, standard/wall, and UT/local indicator
+	// tables; this package does not account for leap seconds and
+	// always interprets transition times as UTC instants.
+	p.skip(leapcnt*8 + isstdcnt + isutcnt);
+
+	if p.err != nil {
+		return nil, p.err;
+	}
+
+	zones := make([]zone, typecnt);
+	for i, tt := range ttinfos {
+		end := int(tt.abbrind);
+		for end < len(abbrev) && abbrev[end] != 0 {
+			end++;
+		}
+		zones[i] = zone{string(abbrev[tt.abbrind:end]), int(tt.offset), tt.isDST != 0};
+	}
+
+	trans := make([]zoneTrans, timecnt);
+	for i := 0; i < timecnt; i++ {
+		trans[i] = zoneTrans{transTimes[i], transTypes[i]};
+	}
+
+	if len(zones) == 0 {
+		zones = []zone{{"UTC", 0, false}};
+	}
+
+	return &Location{name: name, zones: zones, trans: trans}, nil;
+}
+
+// tzParser sequentially decodes the big-endian, fixed-width fields
+// of a TZif header and data block.
+type tzParser struct {
+	data	[]byte;
+	err	os.Error;
+}
+
+func (p *tzParser) fail(msg string) {
+	if p.err == nil {
+		p.err = os.ErrorString("time: malformed tzdata file: " + msg);
+	}
+}
+
+func (p *tzParser) take(n int) []byte {
+	if p.err != nil || len(p.data) < n {
+		p.fail("unexpected end of data");
+		return nil;
+	}
+	b := p.data[0:n];
+	p.data = p.data[n:len(p.data)];
+	return b;
+}
+
+func (p *tzParser) byte() byte {
+	b := p.take(1);
+	if b == nil {
+		return 0;
+	}
+	return b[0];
+}
+
+func (p *tzParser) int32() int {
+	b := p.take(4);
+	if b == nil {
+		return 0;
+	}
+	return int(int32(uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])));
+}
+
+func (p *tzParser) bytes(n int) []byte {
+	return p.take(n);
+}
+
+func (p *tzParser) skip(n int) {
+	p.take(n);
+}
@@ -7,6 +7,7 @@ package Printer
 import (
 	"os";
 	"io";
+	"bytes";
 	"vector";
 	"tabwriter";
 	"flag";
@@ -18,21 +19,47 @@ import (
 )
 
 var (
-	debug = flag.Bool("debug", false, "print debugging information");
 	def = flag.Bool("def", false, "print 'def' instead of 'const', 'type', 'func' - experimental");
+)
 
-	// layout control
-	tabwidth = flag.Int("tabwidth", 8, "tab width");
-	usetabs = flag.Bool("usetabs", true, "align with tabs instead of blanks");
-	newlines = flag.Bool("newlines", true, "respect newlines in source");
-	maxnewlines = flag.Int("maxnewlines", 3, "max. number of consecutive newlines");
 
-	// formatting control
-	comments = flag.Bool("comments", true, "print comments");
-	optsemicolons = flag.Bool("optsemicolons", false, "print optional semicolons");
+// Mode bits control which formatting rules a Config applies.
+const (
+	GenHTML uint = 1 << iota;  // generate HTML
+	RawFormat;  // do not use a tabwriter; emit exactly what the printer produces
+	UseSpaces;  // align with spaces instead of tabs
+	RespectNewlines;  // honor newlines found in the source
+	PrintComments;  // print comments
+	OptSemicolons;  // print optional semicolons
+	Debug;  // print debugging information (comment positions, etc.)
 )
 
 
+// A Config controls the output of Fprint: how the result is
+// formatted (via Mode) and how wide a tab stop is (Tabwidth).
+// The zero Config formats as compactly as possible, in text mode,
+// with 8-column tabs; most callers will want at least
+// RespectNewlines|PrintComments set.
+type Config struct {
+	Mode uint;
+	Tabwidth int;
+
+	// MaxNewlines caps how many consecutive blank-line-producing
+	// newlines from the source are reproduced in the output; extra
+	// ones are dropped. Zero means the default of 3.
+	MaxNewlines int;
+
+	// LinkPrefix, if non-empty, is the hyperlink target Printer uses in
+	// GenHTML mode for identifiers whose declaration it cannot anchor
+	// within the file being printed (e.g. an imported package name, or
+	// a use that precedes its declaration); the link carries no line
+	// number. Without a LinkPrefix, such identifiers are printed
+	// unlinked. LinkPrefix is also prepended to the "<path>.go" link of
+	// an import path, which otherwise uses "/src/lib/".
+	LinkPrefix string;
+}
+
+
 // ----------------------------------------------------------------------------
 // Elementary support
 
@@ -56,16 +83,32 @@ func assert(pred bool) {
 // ----------------------------------------------------------------------------
 // Printer
 
-// Separators - printed in a delayed fashion, depending on context.
+// Non-whitespace separators - unlike the whitespace tokens below, a
+// comma or semicolon is content, not layout: it must always be
+// printed, even immediately before a newline, so it is tracked
+// separately from the ws queue instead of being a token in it.
 const (
 	none = iota;
-	blank;
-	tab;
 	comma;
 	semicolon;
 )
 
 
+// Whitespace queue tokens - pushed by Newline, BreakCol, and the
+// methods that print expressions, statements and declarations, and
+// only realized as actual output by flushws. Queuing them instead of
+// writing immediately lets an interleaved comment be spliced in at
+// the right place, and lets a blank/tab that turns out to precede a
+// newline simply be dropped instead of showing up as trailing white
+// space.
+const (
+	wsBlank = iota;
+	wsTab;
+	wsNewline;
+	wsFormfeed;
+)
+
+
 // Semantic states - control formatting.
 const (
 	normal = iota;
@@ -78,9 +121,12 @@ const (
 type Printer struct {
 	// output
 	text io.Write;
-	
+	written int;  // bytes written so far
+	err os.Error;  // first write error, if any; once set, further writes are dropped
+
 	// formatting control
-	html bool;
+	cfg Config;
+	html bool;  // cfg.Mode&GenHTML != 0, cached since it is tested frequently
 
 	// comments
 	comments *vector.Vector;  // the list of all comments
@@ -94,20 +140,26 @@ type Printer struct {
 
 	// formatting parameters
 	opt_semi bool;  // // true if semicolon separator is optional in statement list
-	separator int;  // pending separator
-	newlines int;  // pending newlines
+	pendingSep int;  // pending comma/semicolon separator, always printed even across a newline
+	ws []int;  // pending whitespace queue (wsBlank/wsTab/wsNewline/wsFormfeed), realized by flushws
 
 	// semantic state
 	state int;  // current semantic state
 	laststate int;  // state for last string
-	
+
 	// expression precedence
 	prec int;
+
+	// HTML support
+	line int;  // current output line number (1-based), counted from newlines emitted
+	linestart bool;  // true if nothing has been printed yet on the current output line
+	declline map[int]int;  // maps a declaration's pos to the output line it was printed on
+	lastAnchorLine int;  // last output line a HtmlIdentifier declaration anchor was emitted for, so multiple names declared on one line don't each get their own <a name=...>
 }
 
 
 func (P *Printer) HasComment(pos int) bool {
-	return *comments && P.cpos < pos;
+	return P.cfg.Mode&PrintComments != 0 && P.cpos < pos;
 }
 
 
@@ -121,12 +173,16 @@ func (P *Printer) NextComment() {
 }
 
 
-func (P *Printer) Init(text io.Write, html bool, comments *vector.Vector) {
+func (P *Printer) Init(text io.Write, cfg Config, comments *vector.Vector) {
 	// writers
 	P.text = text;
-	
+
 	// formatting control
-	P.html = html;
+	P.cfg = cfg;
+	if P.cfg.MaxNewlines == 0 {
+		P.cfg.MaxNewlines = 3;
+	}
+	P.html = cfg.Mode&GenHTML != 0;
 
 	// comments
 	P.comments = comments;
@@ -134,9 +190,14 @@ func (P *Printer) Init(text io.Write, html bool, comments *vector.Vector) {
 	P.NextComment();
 
 	// formatting parameters & semantic state initialized correctly by default
-	
+
 	// expression precedence
 	P.prec = Scanner.LowestPrec;
+
+	// HTML support
+	P.line = 1;
+	P.linestart = true;
+	P.declline = make(map[int]int);
 }
 
 
@@ -144,18 +205,31 @@ func (P *Printer) Init(text io.Write, html bool, comments *vector.Vector) {
 // Printing support
 
 func (P *Printer) htmlEscape(s string) string {
-	if P.html {
+	if !P.html {
+		return s;
+	}
+
+	// Build the escaped string into a buffer rather than recursing and
+	// re-concatenating on every match - the old implementation was
+	// quadratic in the number of '<'/'&' occurrences.
+	var buf bytes.Buffer;
+	last := 0;
+	for i := 0; i < len(s); i++ {
 		var esc string;
-		for i := 0; i < len(s); i++ {
-			switch s[i] {
-			case '<': esc = "&lt;";
-			case '&': esc = "&amp;";
-			default: continue;
-			}
-			return s[0 : i] + esc + P.htmlEscape(s[i+1 : len(s)]);
+		switch s[i] {
+		case '<': esc = "&lt;";
+		case '&': esc = "&amp;";
+		default: continue;
 		}
+		buf.WriteString(s[last : i]);
+		buf.WriteString(esc);
+		last = i + 1;
 	}
-	return s;
+	if last == 0 {
+		return s;	// nothing to escape
+	}
+	buf.WriteString(s[last : len(s)]);
+	return buf.String();
 }
 
 
@@ -177,66 +251,252 @@ func untabify(s string) string {
 
 
 func (P *Printer) Printf(format string, s ...) {
+	if P.err != nil {
+		return;	// sticky - stop writing once something has failed
+	}
 	n, err := fmt.Fprintf(P.text, format, s);
+	P.written += n;
 	if err != nil {
-		panic("print error - exiting");
+		P.err = err;
 	}
 }
 
 
-func (P *Printer) Newline(n int) {
-	if n > 0 {
-		m := int(*maxnewlines);
-		if n > m {
-			n = m;
+// pushws appends tok (a wsBlank/wsTab/wsNewline/wsFormfeed token) to
+// the pending whitespace queue. A formfeed immediately following
+// another formfeed is dropped - a column-block break is idempotent,
+// so BreakCol and a blank line both asking for one at once must not
+// turn into two "\f"s.
+func (P *Printer) pushws(tok int) {
+	if tok == wsFormfeed {
+		if n := len(P.ws); n > 0 && P.ws[n-1] == wsFormfeed {
+			return;
 		}
-		for ; n > 0; n-- {
-			P.Printf("\n");
-		}
-		for i := P.indentation; i > 0; i-- {
-			P.Printf("\t");
+	}
+	n := len(P.ws);
+	if n == cap(P.ws) {
+		buf := make([]int, n, 2*n+8);
+		for i, t := range P.ws {
+			buf[i] = t;
 		}
+		P.ws = buf;
 	}
+	P.ws = P.ws[0 : n+1];
+	P.ws[n] = tok;
+}
+
+
+// hasPendingNewline reports whether the whitespace queue currently
+// ends in a newline - used to avoid scheduling a redundant one after
+// a //-style comment that already ends a line.
+func (P *Printer) hasPendingNewline() bool {
+	n := len(P.ws);
+	return n > 0 && P.ws[n-1] == wsNewline;
+}
+
+
+// takePendingNewlines removes any wsNewline tokens from the end of the
+// whitespace queue and returns how many newlines were removed. A
+// wsFormfeed immediately preceding them is left queued rather than
+// removed: like the old colbreak flag it latches independently of any
+// particular newline count and is only consumed the next time the
+// queue is actually flushed, whether that flush belongs to the
+// newlines taken here or to some other break printed in the meantime.
+// By construction nothing is ever queued after a pending newline
+// before it is realized, so the tokens removed here are always a
+// clean suffix of the queue.
+func (P *Printer) takePendingNewlines() int {
+	n := len(P.ws);
+	count := 0;
+	for n > 0 && P.ws[n-1] == wsNewline {
+		count++;
+		n--;
+	}
+	P.ws = P.ws[0:n];
+	return count;
 }
 
 
-func (P *Printer) TaggedString(pos int, tag, s, endtag string) {
-	// use estimate for pos if we don't have one
-	if pos == 0 {
-		pos = P.lastpos;
+// cancelNewlines drops any line breaks scheduled so far without
+// printing them - used when an enclosing construct must suppress a
+// blank line that would otherwise follow (e.g. a function literal
+// used as an expression, which must not leave its body's trailing
+// newline dangling).
+func (P *Printer) cancelNewlines() {
+	P.takePendingNewlines();
+}
+
+
+// clearSeparator drops the pending comma/semicolon separator and any
+// still-unflushed blank/tab at the tail of the whitespace queue,
+// without printing either - used where a caller needs to guarantee no
+// separator of any kind appears before what it prints next.
+func (P *Printer) clearSeparator() {
+	P.pendingSep = none;
+	if n := len(P.ws); n > 0 {
+		switch P.ws[n-1] {
+		case wsBlank, wsTab:
+			P.ws = P.ws[0 : n-1];
+		}
 	}
+}
 
-	// --------------------------------
-	// print pending separator, if any
-	// - keep track of white space printed for better comment formatting
-	// TODO print white space separators after potential comments and newlines
-	// (currently, we may get trailing white space before a newline)
+
+// flushPendingSep prints the pending comma/semicolon separator, if
+// any, and returns the trailing character printed (0 if none) for the
+// same reason flushws does. Unlike the whitespace queue, a comma or
+// semicolon is always printed even when a line break follows -
+// dropping one would silently corrupt the output. If withBlank is
+// true, the comma/semicolon is followed by a blank, the way it reads
+// when ordinary text comes next on the same line; withBlank should be
+// false when a newline is about to follow, since the blank would only
+// show up as trailing white space.
+func (P *Printer) flushPendingSep(withBlank bool) int {
 	trailing_char := 0;
-	switch P.separator {
-	case none:	// nothing to do
-	case blank:
-		P.Printf(" ");
-		trailing_char = ' ';
-	case tab:
-		P.Printf("\t");
-		trailing_char = '\t';
+	switch P.pendingSep {
 	case comma:
 		P.Printf(",");
-		if P.newlines == 0 {
+		if withBlank {
 			P.Printf(" ");
 			trailing_char = ' ';
 		}
 	case semicolon:
 		if P.level > 0 {	// no semicolons at level 0
 			P.Printf(";");
-			if P.newlines == 0 {
+			if withBlank {
+				P.Printf(" ");
+				trailing_char = ' ';
+			}
+		}
+	}
+	P.pendingSep = none;
+	return trailing_char;
+}
+
+
+// flushws realizes the pending whitespace queue - writing blanks,
+// tabs, formfeeds and (capped, indented) newlines to the output - and
+// empties the queue. If dropWS is true, queued blanks/tabs are
+// discarded instead of printed, since a newline is about to follow
+// them and they would only show up as trailing white space. It
+// returns the last whitespace character actually written (' ' or
+// '\t'), or 0, so callers can use it to decide how to space a comment
+// that immediately follows.
+func (P *Printer) flushws(dropWS bool) int {
+	trailing_char := 0;
+	i := 0;
+	for i < len(P.ws) {
+		switch P.ws[i] {
+		case wsBlank:
+			if !dropWS {
 				P.Printf(" ");
 				trailing_char = ' ';
 			}
+			i++;
+		case wsTab:
+			if !dropWS {
+				P.Printf("\t");
+				trailing_char = '\t';
+			}
+			i++;
+		case wsFormfeed:
+			P.Printf("\f");
+			trailing_char = 0;
+			i++;
+		case wsNewline:
+			j := i;
+			for j < len(P.ws) && P.ws[j] == wsNewline {
+				j++;
+			}
+			n := j - i;
+			if m := P.cfg.MaxNewlines; n > m {
+				n = m;
+			}
+			for ; n > 0; n-- {
+				P.Printf("\n");
+				P.line++;
+			}
+			for k := P.indentation; k > 0; k-- {
+				P.Printf("\t");
+			}
+			P.linestart = true;
+			trailing_char = 0;
+			i = j;
+		default:
+			panic("UNREACHABLE");
 		}
-	default:	panic("UNREACHABLE");
 	}
-	P.separator = none;
+	P.ws = P.ws[0:0];
+	return trailing_char;
+}
+
+
+// BreakCol schedules a tabwriter column-block break before the next
+// line: cells printed so far are aligned only among themselves, not
+// with the cells of whatever comes after the break. Use this to keep
+// elastic-tabstop alignment (e.g. in Fields or DoDeclList) from
+// bleeding across runs of declarations that don't have the same shape.
+func (P *Printer) BreakCol() {
+	// queue the formfeed ahead of any pending newlines, the same way
+	// Newline does, so the queue still ends in a clean run of newlines
+	n := P.takePendingNewlines();
+	P.pushws(wsFormfeed);
+	for ; n > 0; n-- {
+		P.pushws(wsNewline);
+	}
+}
+
+
+// Newline schedules n line breaks to be written before whatever is
+// printed next; actual output happens later, when flushws realizes
+// the queue. A blank line (n > 1) also schedules a formfeed ahead of
+// the newlines, to reset the tabwriter's column alignment the same
+// way an explicit BreakCol does.
+func (P *Printer) Newline(n int) {
+	if n > 1 {
+		P.pushws(wsFormfeed);
+	}
+	for ; n > 0; n-- {
+		P.pushws(wsNewline);
+	}
+}
+
+
+// taggedLineStart emits an empty <a id="L%d"> anchor for pos's output
+// line the first time anything is printed on that line, so external
+// tools or users can link straight to file.html#L<n>. TaggedString
+// calls it for every token and flushBeforeToken for every comment;
+// pos is accepted for symmetry with TaggedString but is unused - the
+// line number itself comes from P.line, since the scanner doesn't expose a
+// pos->line mapping and every token on the same output line shares it.
+func (P *Printer) taggedLineStart(pos int) {
+	if P.html && P.linestart {
+		P.linestart = false;
+		P.Printf(`<a id="L%d"></a>`, P.line);
+	}
+}
+
+
+// flushBeforeToken interleaves any comments up to pos and flushes the
+// pending separator and any scheduled newlines, leaving the printer
+// ready to print the token at pos. It returns the output line number
+// that token will land on - the same line taggedLineStart's anchor
+// (if any) resolves to - so a caller that needs to embed the final
+// line number in the token itself (e.g. HtmlIdentifier's declaration
+// anchor) can call this first and use the result. TaggedString calls
+// this too; calling it twice for the same token is harmless; the
+// second call simply finds nothing left to flush.
+func (P *Printer) flushBeforeToken(pos int) int {
+	// --------------------------------
+	// queue the pending separator
+	// - whether it prints as trailing white space (blank, tab), as
+	//   a character followed by white space (comma, semicolon), or
+	//   not at all depends on what follows: ordinary text, a comment
+	//   on the same line, or a newline - which isn't known until the
+	//   code below has looked ahead, so defer the actual printing to
+	//   flushPendingSep/flushws below
+	// - keep track of white space printed for better comment formatting
+	trailing_char := 0;
 
 	// --------------------------------
 	// interleave comments, if any
@@ -256,15 +516,48 @@ func (P *Printer) TaggedString(pos int, tag, s, endtag string) {
 			if nlcount > 0 || P.cpos == 0 {
 				// only white space before comment on this line
 				// or file starts with comment
+				// - a newline is coming, so a pending blank/tab
+				//   separator would only become trailing white
+				//   space; drop it, but keep a comma or semicolon
+				// - a newline count already queued belongs to
+				//   whatever preceded this comment and is still owed
+				//   *after* it (just as the old P.newlines field sat
+				//   untouched across this whole loop); stash it so
+				//   the comment's own nlcount-derived break doesn't
+				//   get merged with it, and let the final pending-
+				//   newlines step below resolve it as usual. Any
+				//   formfeed is left in the queue rather than stashed:
+				//   like the old colbreak flag it latches until the
+				//   next flush, comment break or not
+				stashed := P.takePendingNewlines();
+				P.flushPendingSep(false);
+				P.flushws(true);
 				// - indent
-				if !*newlines && P.cpos != 0 {
+				if P.cfg.Mode&RespectNewlines == 0 && P.cpos != 0 {
 					nlcount = 1;
 				}
 				P.Newline(nlcount);
+				P.flushws(true);
 				nlcount = 0;
+				for ; stashed > 0; stashed-- {
+					P.pushws(wsNewline);
+				}
 
 			} else {
 				// black space before comment on this line
+				// - the comment follows on the same line, so the
+				//   separator is printed normally, but a newline count
+				//   already queued (see above) is still not ours to
+				//   resolve - stash and restore it around the flush
+				//   the same way
+				stashed := P.takePendingNewlines();
+				trailing_char = P.flushPendingSep(true);
+				if tc := P.flushws(false); tc != 0 {
+					trailing_char = tc;
+				}
+				for ; stashed > 0; stashed-- {
+					P.pushws(wsNewline);
+				}
 				if ctext[1] == '/' {
 					//-style comment
 					// - put in next cell unless a scope was just opened
@@ -292,17 +585,18 @@ func (P *Printer) TaggedString(pos int, tag, s, endtag string) {
 			}
 
 			// print comment
-			if *debug {
+			if P.cfg.Mode&Debug != 0 {
 				P.Printf("[%d]", P.cpos);
 			}
+			P.taggedLineStart(P.cpos);
 			// calling untabify increases the change for idempotent output
 			// since tabs in comments are also interpreted by tabwriter
 			P.Printf("%s", P.htmlEscape(untabify(ctext)));
 
 			if ctext[1] == '/' {
 				//-style comments must end in newline
-				if P.newlines == 0 {  // don't add newlines if not needed
-					P.newlines = 1;
+				if !P.hasPendingNewline() {  // don't add newlines if not needed
+					P.pushws(wsNewline);
 				}
 			}
 		}
@@ -326,23 +620,47 @@ func (P *Printer) TaggedString(pos int, tag, s, endtag string) {
 
 	// --------------------------------
 	// print pending newlines
-	if *newlines && (P.newlines > 0 || P.state == inside_list) && nlcount > P.newlines {
+	pending := P.takePendingNewlines();
+	if P.cfg.Mode&RespectNewlines != 0 && (pending > 0 || P.state == inside_list) && nlcount > pending {
 		// Respect additional newlines in the source, but only if we
 		// enabled this feature (newlines.BVal()) and we are expecting
-		// newlines (P.newlines > 0 || P.state == inside_list).
+		// newlines (pending > 0 || P.state == inside_list).
 		// Otherwise - because we don't have all token positions - we
 		// get funny formatting.
-		P.newlines = nlcount;
+		pending = nlcount;
 	}
 	nlcount = 0;
-	P.Newline(P.newlines);
-	P.newlines = 0;
+	sep_tc := P.flushPendingSep(pending <= 0);
+	if pending > 0 {
+		// a newline is coming - drop a pending blank/tab separator
+		P.flushws(true);
+	} else {
+		trailing_char = P.flushws(false);
+		if trailing_char == 0 {
+			trailing_char = sep_tc;
+		}
+	}
+	P.Newline(pending);
+	P.flushws(false);	// realize the newlines just scheduled before printing below
+
+	return P.line;
+}
+
+
+func (P *Printer) TaggedString(pos int, tag, s, endtag string) {
+	// use estimate for pos if we don't have one
+	if pos == 0 {
+		pos = P.lastpos;
+	}
+
+	P.flushBeforeToken(pos);
 
 	// --------------------------------
 	// print string
-	if *debug {
+	if P.cfg.Mode&Debug != 0 {
 		P.Printf("[%d]", pos);
 	}
+	P.taggedLineStart(pos);
 	P.Printf("%s%s%s", tag, P.htmlEscape(s), endtag);
 
 	// --------------------------------
@@ -423,13 +741,39 @@ func (P *Printer) HtmlIdentifier(x *AST.Ident) {
 	if P.html && obj.Kind != SymbolTable.NONE {
 		// depending on whether we have a declaration or use, generate different html
 		// - no need to htmlEscape ident
-		id := Utils.IntToString(obj.Id, 10);
 		if x.Pos_ == obj.Pos {
-			// probably the declaration of x
-			P.TaggedString(x.Pos_, `<a name="id` + id + `">`, obj.Ident, `</a>`);
+			// probably the declaration of x - anchor it by output line,
+			// so the link also works as a plain file.html#L<n> reference
+			// - resolve pending newlines/comments first so the line
+			//   number reflects where x itself will actually print,
+			//   not wherever P.line happened to be before they flush
+			//   (e.g. every field after the first in Fields(), or
+			//   every spec after the first in a parenthesized decl)
+			line := P.flushBeforeToken(x.Pos_);
+			P.declline[obj.Pos] = line;
+			if line == P.lastAnchorLine {
+				// another name declared on the same line already got
+				// this line's anchor - a second <a name="L..."> for
+				// the same line would be invalid, ambiguous HTML
+				P.TaggedString(x.Pos_, "", obj.Ident, "");
+			} else {
+				P.lastAnchorLine = line;
+				id := Utils.IntToString(line, 10);
+				P.TaggedString(x.Pos_, `<a name="L` + id + `">`, obj.Ident, `</a>`);
+			}
+		} else if line, found := P.declline[obj.Pos]; found {
+			// probably not the declaration of x, and we've already
+			// printed (and anchored) its declaration
+			id := Utils.IntToString(line, 10);
+			P.TaggedString(x.Pos_, `<a href="#L` + id + `">`, obj.Ident, `</a>`);
+		} else if P.cfg.LinkPrefix != "" {
+			// the declaration hasn't been printed yet (a forward
+			// reference) or lives in another file entirely - point at
+			// the prefixed location without a line number, since we
+			// don't know it
+			P.TaggedString(x.Pos_, `<a href="` + P.cfg.LinkPrefix + `">`, obj.Ident, `</a>`);
 		} else {
-			// probably not the declaration of x
-			P.TaggedString(x.Pos_, `<a href="#id` + id + `">`, obj.Ident, `</a>`);
+			P.String(x.Pos_, obj.Ident);
 		}
 	} else {
 		P.String(x.Pos_, obj.Ident);
@@ -440,8 +784,12 @@ func (P *Printer) HtmlIdentifier(x *AST.Ident) {
 func (P *Printer) HtmlPackageName(pos int, name string) {
 	if P.html {
 		sname := name[1 : len(name)-1];  // strip quotes  TODO do this elsewhere eventually
-		// TODO CAPITAL HACK BELOW FIX THIS
-		P.TaggedString(pos, `"<a href="/src/lib/` + sname + `.go">`, sname, `</a>"`);
+		prefix := P.cfg.LinkPrefix;
+		if prefix == "" {
+			// TODO CAPITAL HACK BELOW FIX THIS
+			prefix = "/src/lib/";
+		}
+		P.TaggedString(pos, `"<a href="` + prefix + sname + `.go">`, sname, `</a>"`);
 	} else {
 		P.String(pos, name);
 	}
@@ -457,7 +805,7 @@ func (P *Printer) Idents(list []*AST.Ident) {
 	for i, x := range list {
 		if i > 0 {
 			P.Token(0, Scanner.COMMA);
-			P.separator = blank;
+			P.pushws(wsBlank);
 			P.state = inside_list;
 		}
 		P.Expr(x);
@@ -470,11 +818,11 @@ func (P *Printer) Parameters(list []*AST.Field) {
 	if len(list) > 0 {
 		for i, par := range list {
 			if i > 0 {
-				P.separator = comma;
+				P.pendingSep = comma;
 			}
 			if len(par.Idents) > 0 {
 				P.Idents(par.Idents);
-				P.separator = blank
+				P.pushws(wsBlank)
 			};
 			P.Expr(par.Typ);
 		}
@@ -488,7 +836,7 @@ func (P *Printer) Parameters(list []*AST.Field) {
 func (P *Printer) Signature(sig *AST.Signature) {
 	P.Parameters(sig.Params);
 	if sig.Result != nil {
-		P.separator = blank;
+		P.pushws(wsBlank);
 
 		if len(sig.Result) == 1 && sig.Result[0].Idents == nil {
 			// single anonymous result
@@ -505,21 +853,37 @@ func (P *Printer) Signature(sig *AST.Signature) {
 }
 
 
+// fieldShape describes enough of a field's layout - whether it has
+// names and (for struct fields) a tag - to tell whether it lines up
+// column-for-column with a neighboring field.
+type fieldShape struct {
+	has_idents bool;
+	has_tag bool;
+}
+
+
 func (P *Printer) Fields(list []*AST.Field, end int, is_interface bool) {
 	P.state = opening_scope;
-	P.separator = blank;
+	P.pushws(wsBlank);
 	P.Token(0, Scanner.LBRACE);
 
 	if len(list) > 0 {
-		P.newlines = 1;
+		P.Newline(1);
+		var prev fieldShape;
 		for i, fld := range list {
+			shape := fieldShape{len(fld.Idents) > 0, !is_interface && fld.Tag != nil};
 			if i > 0 {
-				P.separator = semicolon;
-				P.newlines = 1;
+				if shape != prev {
+					// this field's shape doesn't match the previous
+					// one - don't align the two as columns
+					P.BreakCol();
+				}
+				P.pendingSep = semicolon;
+				P.Newline(1);
 			}
-			if len(fld.Idents) > 0 {
+			if shape.has_idents {
 				P.Idents(fld.Idents);
-				P.separator = tab
+				P.pushws(wsTab)
 			};
 			if is_interface {
 				if ftyp, is_ftyp := fld.Typ.(*AST.FunctionType); is_ftyp {
@@ -530,12 +894,13 @@ func (P *Printer) Fields(list []*AST.Field, end int, is_interface bool) {
 			} else {
 				P.Expr(fld.Typ);
 				if fld.Tag != nil {
-					P.separator = tab;
+					P.pushws(wsTab);
 					P.Expr(fld.Tag);
 				}
 			}
+			prev = shape;
 		}
-		P.newlines = 1;
+		P.Newline(1);
 	}
 
 	P.state = closing_scope;
@@ -566,7 +931,7 @@ func (P *Printer) DoBinaryExpr(x *AST.BinaryExpr) {
 		// (don't use binary expression printing because of different spacing)
 		P.Expr(x.X);
 		P.Token(x.Pos_, Scanner.COMMA);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.state = inside_list;
 		P.Expr(x.Y);
 	} else {
@@ -575,9 +940,9 @@ func (P *Printer) DoBinaryExpr(x *AST.BinaryExpr) {
 			P.Token(0, Scanner.LPAREN);
 		}
 		P.Expr1(x.X, prec);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Token(x.Pos_, x.Tok);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Expr1(x.Y, prec);
 		if prec < P.prec {
 			P.Token(0, Scanner.RPAREN);
@@ -593,7 +958,7 @@ func (P *Printer) DoUnaryExpr(x *AST.UnaryExpr) {
 	}
 	P.Token(x.Pos_, x.Tok);
 	if x.Tok == Scanner.RANGE {
-		P.separator = blank;
+		P.pushws(wsBlank);
 	}
 	P.Expr1(x.X, prec);
 	if prec < P.prec {
@@ -610,9 +975,9 @@ func (P *Printer) DoBasicLit(x *AST.BasicLit) {
 func (P *Printer) DoFunctionLit(x *AST.FunctionLit) {
 	P.Token(x.Pos_, Scanner.FUNC);
 	P.Signature(x.Typ);
-	P.separator = blank;
+	P.pushws(wsBlank);
 	P.Block(x.Body, true);
-	P.newlines = 0;
+	P.cancelNewlines();
 }
 
 
@@ -699,13 +1064,30 @@ func (P *Printer) DoInterfaceType(x *AST.InterfaceType) {
 
 
 func (P *Printer) DoSliceType(x *AST.SliceType) {
-	unimplemented();
+	// x[Low:High] or, when Max is present, x[Low:High:Max]; Low and High
+	// are printed only when non-nil (a[:], a[i:], a[:j], a[i:j] must all
+	// render without a spurious zero), but the first ':' is always there
+	// since it's what makes this a slice rather than an index expression.
+	P.Expr1(x.X, Scanner.HighestPrec);
+	P.Token(x.Pos_, Scanner.LBRACK);
+	if x.Low != nil {
+		P.Expr1(x.Low, 0);
+	}
+	P.Token(0, Scanner.COLON);
+	if x.High != nil {
+		P.Expr1(x.High, 0);
+	}
+	if x.Max != nil {
+		P.Token(0, Scanner.COLON);
+		P.Expr1(x.Max, 0);
+	}
+	P.Token(0, Scanner.RBRACK);
 }
 
 
 func (P *Printer) DoMapType(x *AST.MapType) {
 	P.Token(x.Pos_, Scanner.MAP);
-	P.separator = blank;
+	P.pushws(wsBlank);
 	P.Token(0, Scanner.LBRACK);
 	P.Expr(x.Key);
 	P.Token(0, Scanner.RBRACK);
@@ -722,10 +1104,10 @@ func (P *Printer) DoChannelType(x *AST.ChannelType) {
 		P.Token(0, Scanner.CHAN);
 	case AST.SEND:
 		P.Token(x.Pos_, Scanner.CHAN);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Token(0, Scanner.ARROW);
 	}
-	P.separator = blank;
+	P.pushws(wsBlank);
 	P.Expr(x.Val);
 }
 
@@ -737,7 +1119,7 @@ func (P *Printer) Expr1(x AST.Expr, prec1 int) {
 
 	saved_prec := P.prec;
 	P.prec = prec1;
-	x.Visit(P);
+	P.node(x);
 	P.prec = saved_prec;
 }
 
@@ -750,23 +1132,18 @@ func (P *Printer) Expr(x AST.Expr) {
 // ----------------------------------------------------------------------------
 // Statements
 
-func (P *Printer) Stat(s AST.Stat) {
-	s.Visit(P);
-}
-
-
 func (P *Printer) StatementList(list *vector.Vector) {
 	for i := 0; i < list.Len(); i++ {
 		if i == 0 {
-			P.newlines = 1;
+			P.Newline(1);
 		} else {  // i > 0
 			if !P.opt_semi {
 				// semicolon is required
-				P.separator = semicolon;
+				P.pendingSep = semicolon;
 			}
 		}
-		P.Stat(list.At(i).(AST.Stat));
-		P.newlines = 1;
+		P.node(list.At(i).(AST.Stat));
+		P.Newline(1);
 		P.state = inside_list;
 	}
 }
@@ -782,8 +1159,8 @@ func (P *Printer) Block(b *AST.Block, indent bool) {
 	if !indent {
 		P.indentation++;
 	}
-	if !*optsemicolons {
-		P.separator = none;
+	if P.cfg.Mode&OptSemicolons == 0 {
+		P.clearSeparator();
 	}
 	P.state = closing_scope;
 	if b.Tok == Scanner.LBRACE {
@@ -795,8 +1172,6 @@ func (P *Printer) Block(b *AST.Block, indent bool) {
 }
 
 
-func (P *Printer) Decl(d AST.Decl);
-
 func (P *Printer) DoBadStat(s *AST.BadStat) {
 	panic();
 }
@@ -811,7 +1186,7 @@ func (P *Printer) DoLabelDecl(s *AST.LabelDecl) {
 
 
 func (P *Printer) DoDeclarationStat(s *AST.DeclarationStat) {
-	P.Decl(s.Decl);
+	P.node(s.Decl);
 }
 
 
@@ -825,7 +1200,7 @@ func (P *Printer) DoExpressionStat(s *AST.ExpressionStat) {
 	case Scanner.RETURN, Scanner.GO, Scanner.DEFER:
 		P.Token(s.Pos, s.Tok);
 		if s.Expr != nil {
-			P.separator = blank;
+			P.pushws(wsBlank);
 			P.Expr(s.Expr);
 		}
 	default:
@@ -841,7 +1216,7 @@ func (P *Printer) DoCompositeStat(s *AST.CompositeStat) {
 
 
 func (P *Printer) ControlClause(isForStat bool, init AST.Stat, expr AST.Expr, post AST.Stat) {
-	P.separator = blank;
+	P.pushws(wsBlank);
 	if init == nil && post == nil {
 		// no semicolons required
 		if expr != nil {
@@ -851,24 +1226,24 @@ func (P *Printer) ControlClause(isForStat bool, init AST.Stat, expr AST.Expr, po
 		// all semicolons required
 		// (they are not separators, print them explicitly)
 		if init != nil {
-			P.Stat(init);
-			P.separator = none;
+			P.node(init);
+			P.clearSeparator();
 		}
 		P.Token(0, Scanner.SEMICOLON);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		if expr != nil {
 			P.Expr(expr);
-			P.separator = none;
+			P.clearSeparator();
 		}
 		if isForStat {
 			P.Token(0, Scanner.SEMICOLON);
-			P.separator = blank;
+			P.pushws(wsBlank);
 			if post != nil {
-				P.Stat(post);
+				P.node(post);
 			}
 		}
 	}
-	P.separator = blank;
+	P.pushws(wsBlank);
 }
 
 
@@ -877,10 +1252,10 @@ func (P *Printer) DoIfStat(s *AST.IfStat) {
 	P.ControlClause(false, s.Init, s.Cond, nil);
 	P.Block(s.Body, true);
 	if s.Else != nil {
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Token(0, Scanner.ELSE);
-		P.separator = blank;
-		P.Stat(s.Else);
+		P.pushws(wsBlank);
+		P.node(s.Else);
 	}
 }
 
@@ -895,7 +1270,7 @@ func (P *Printer) DoForStat(s *AST.ForStat) {
 func (P *Printer) DoCaseClause(s *AST.CaseClause) {
 	if s.Expr != nil {
 		P.Token(s.Pos, Scanner.CASE);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Expr(s.Expr);
 	} else {
 		P.Token(s.Pos, Scanner.DEFAULT);
@@ -906,7 +1281,7 @@ func (P *Printer) DoCaseClause(s *AST.CaseClause) {
 	P.indentation++;
 	P.StatementList(s.Body.List);
 	P.indentation--;
-	P.newlines = 1;
+	P.Newline(1);
 }
 
 
@@ -919,7 +1294,7 @@ func (P *Printer) DoSwitchStat(s *AST.SwitchStat) {
 
 func (P *Printer) DoSelectStat(s *AST.SelectStat) {
 	P.Token(s.Pos, Scanner.SELECT);
-	P.separator = blank;
+	P.pushws(wsBlank);
 	P.Block(s.Body, false);
 }
 
@@ -927,7 +1302,7 @@ func (P *Printer) DoSelectStat(s *AST.SelectStat) {
 func (P *Printer) DoControlFlowStat(s *AST.ControlFlowStat) {
 	P.Token(s.Pos, s.Tok);
 	if s.Label != nil {
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Expr(s.Label);
 	}
 }
@@ -949,14 +1324,14 @@ func (P *Printer) DoBadDecl(d *AST.BadDecl) {
 func (P *Printer) DoImportDecl(d *AST.ImportDecl) {
 	if d.Pos > 0 {
 		P.Token(d.Pos, Scanner.IMPORT);
-		P.separator = blank;
+		P.pushws(wsBlank);
 	}
 	if d.Ident != nil {
 		P.Expr(d.Ident);
 	} else {
 		P.String(d.Path.Pos(), "");  // flush pending ';' separator/newlines
 	}
-	P.separator = tab;
+	P.pushws(wsTab);
 	if lit, is_lit := d.Path.(*AST.BasicLit); is_lit && lit.Tok == Scanner.STRING {
 		P.HtmlPackageName(lit.Pos_, lit.Val);
 	} else {
@@ -964,84 +1339,109 @@ func (P *Printer) DoImportDecl(d *AST.ImportDecl) {
 		// import "foo" "bar"
 		P.Expr(d.Path);
 	}
-	P.newlines = 2;
+	P.Newline(2);
 }
 
 
 func (P *Printer) DoConstDecl(d *AST.ConstDecl) {
 	if d.Pos > 0 {
 		P.Token(d.Pos, Scanner.CONST);
-		P.separator = blank;
+		P.pushws(wsBlank);
 	}
 	P.Idents(d.Idents);
 	if d.Typ != nil {
-		P.separator = blank;  // TODO switch to tab? (indentation problem with structs)
+		P.pushws(wsBlank);  // TODO switch to tab? (indentation problem with structs)
 		P.Expr(d.Typ);
 	}
 	if d.Vals != nil {
-		P.separator = tab;
+		P.pushws(wsTab);
 		P.Token(0, Scanner.ASSIGN);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Expr(d.Vals);
 	}
-	P.newlines = 2;
+	P.Newline(2);
 }
 
 
 func (P *Printer) DoTypeDecl(d *AST.TypeDecl) {
 	if d.Pos > 0 {
 		P.Token(d.Pos, Scanner.TYPE);
-		P.separator = blank;
+		P.pushws(wsBlank);
 	}
 	P.Expr(d.Ident);
-	P.separator = blank;  // TODO switch to tab? (but indentation problem with structs)
+	P.pushws(wsBlank);  // TODO switch to tab? (but indentation problem with structs)
 	P.Expr(d.Typ);
-	P.newlines = 2;
+	P.Newline(2);
 }
 
 
 func (P *Printer) DoVarDecl(d *AST.VarDecl) {
 	if d.Pos > 0 {
 		P.Token(d.Pos, Scanner.VAR);
-		P.separator = blank;
+		P.pushws(wsBlank);
 	}
 	P.Idents(d.Idents);
 	if d.Typ != nil {
-		P.separator = blank;  // TODO switch to tab? (indentation problem with structs)
+		P.pushws(wsBlank);  // TODO switch to tab? (indentation problem with structs)
 		P.Expr(d.Typ);
-		//P.separator = P.Type(d.Typ);
 	}
 	if d.Vals != nil {
-		P.separator = tab;
+		P.pushws(wsTab);
 		P.Token(0, Scanner.ASSIGN);
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Expr(d.Vals);
 	}
-	P.newlines = 2;
+	P.Newline(2);
 }
 
 
 func (P *Printer) DoFuncDecl(d *AST.FuncDecl) {
 	P.Token(d.Pos_, Scanner.FUNC);
-	P.separator = blank;
+	P.pushws(wsBlank);
 	if recv := d.Recv; recv != nil {
 		// method: print receiver
 		P.Token(0, Scanner.LPAREN);
 		if len(recv.Idents) > 0 {
 			P.Expr(recv.Idents[0]);
-			P.separator = blank;
+			P.pushws(wsBlank);
 		}
 		P.Expr(recv.Typ);
 		P.Token(0, Scanner.RPAREN);
-		P.separator = blank;
+		P.pushws(wsBlank);
 	}
 	P.Expr(d.Ident);
 	P.Signature(d.Sig);
 	if d.Body != nil {
-		P.separator = blank;
+		P.pushws(wsBlank);
 		P.Block(d.Body, true);
 	}
-	P.newlines = 2;
+	P.Newline(2);
+}
+
+
+// declShape describes enough of a const/var/type/import spec's layout
+// - the number of names and whether it has a type and/or values - to
+// tell whether it lines up column-for-column with its neighbor in a
+// parenthesized declaration list.
+type declShape struct {
+	nidents int;
+	has_typ bool;
+	has_vals bool;
+}
+
+
+func shapeOf(d AST.Decl) declShape {
+	switch t := d.(type) {
+	case *AST.ConstDecl:
+		return declShape{len(t.Idents), t.Typ != nil, t.Vals != nil};
+	case *AST.VarDecl:
+		return declShape{len(t.Idents), t.Typ != nil, t.Vals != nil};
+	case *AST.TypeDecl:
+		return declShape{1, true, false};
+	case *AST.ImportDecl:
+		return declShape{0, false, t.Ident != nil};
+	}
+	return declShape{0, false, false};
 }
 
 
@@ -1051,30 +1451,91 @@ func (P *Printer) DoDeclList(d *AST.DeclList) {
 	} else {
 		P.String(d.Pos, "def");
 	}
-	P.separator = blank;
+	P.pushws(wsBlank);
 
 	// group of parenthesized declarations
 	P.state = opening_scope;
 	P.Token(0, Scanner.LPAREN);
 	if len(d.List) > 0 {
-		P.newlines = 1;
+		P.Newline(1);
+		var prev declShape;
 		for i := 0; i < len(d.List); i++ {
+			shape := shapeOf(d.List[i]);
 			if i > 0 {
-				P.separator = semicolon;
+				if shape != prev {
+					// this spec's shape doesn't match the previous
+					// one - don't align the two as columns
+					P.BreakCol();
+				}
+				P.pendingSep = semicolon;
 			}
-			P.Decl(d.List[i]);
-			P.newlines = 1;
+			P.node(d.List[i]);
+			P.Newline(1);
+			prev = shape;
 		}
 	}
 	P.state = closing_scope;
 	P.Token(d.End, Scanner.RPAREN);
 	P.opt_semi = true;
-	P.newlines = 2;
-}
-
+	P.Newline(2);
+}
+
+
+// node dispatches to the Do* method matching n's concrete type. This
+// replaces the old scheme of requiring every AST node to implement
+// Visit(*Printer): adding a new node kind now only means adding a
+// case here and a matching Do* method, without touching the AST
+// package at all.
+func (P *Printer) node(n interface{}) {
+	switch t := n.(type) {
+	// expressions
+	case *AST.BadExpr: P.DoBadExpr(t);
+	case *AST.Ident: P.DoIdent(t);
+	case *AST.BinaryExpr: P.DoBinaryExpr(t);
+	case *AST.UnaryExpr: P.DoUnaryExpr(t);
+	case *AST.BasicLit: P.DoBasicLit(t);
+	case *AST.FunctionLit: P.DoFunctionLit(t);
+	case *AST.Group: P.DoGroup(t);
+	case *AST.Selector: P.DoSelector(t);
+	case *AST.TypeGuard: P.DoTypeGuard(t);
+	case *AST.Index: P.DoIndex(t);
+	case *AST.Call: P.DoCall(t);
+	case *AST.Ellipsis: P.DoEllipsis(t);
+	case *AST.ArrayType: P.DoArrayType(t);
+	case *AST.StructType: P.DoStructType(t);
+	case *AST.PointerType: P.DoPointerType(t);
+	case *AST.FunctionType: P.DoFunctionType(t);
+	case *AST.InterfaceType: P.DoInterfaceType(t);
+	case *AST.SliceType: P.DoSliceType(t);
+	case *AST.MapType: P.DoMapType(t);
+	case *AST.ChannelType: P.DoChannelType(t);
+
+	// statements
+	case *AST.BadStat: P.DoBadStat(t);
+	case *AST.LabelDecl: P.DoLabelDecl(t);
+	case *AST.DeclarationStat: P.DoDeclarationStat(t);
+	case *AST.ExpressionStat: P.DoExpressionStat(t);
+	case *AST.CompositeStat: P.DoCompositeStat(t);
+	case *AST.IfStat: P.DoIfStat(t);
+	case *AST.ForStat: P.DoForStat(t);
+	case *AST.CaseClause: P.DoCaseClause(t);
+	case *AST.SwitchStat: P.DoSwitchStat(t);
+	case *AST.SelectStat: P.DoSelectStat(t);
+	case *AST.ControlFlowStat: P.DoControlFlowStat(t);
+	case *AST.EmptyStat: P.DoEmptyStat(t);
+
+	// declarations
+	case *AST.BadDecl: P.DoBadDecl(t);
+	case *AST.ImportDecl: P.DoImportDecl(t);
+	case *AST.ConstDecl: P.DoConstDecl(t);
+	case *AST.TypeDecl: P.DoTypeDecl(t);
+	case *AST.VarDecl: P.DoVarDecl(t);
+	case *AST.FuncDecl: P.DoFuncDecl(t);
+	case *AST.DeclList: P.DoDeclList(t);
 
-func (P *Printer) Decl(d AST.Decl) {
-	d.Visit(P);
+	default:
+		panic(fmt.Sprintf("pretty.node: unhandled node type %T", n));
+	}
 }
 
 
@@ -1083,36 +1544,86 @@ func (P *Printer) Decl(d AST.Decl) {
 
 func (P *Printer) Program(p *AST.Program) {
 	P.Token(p.Pos, Scanner.PACKAGE);
-	P.separator = blank;
+	P.pushws(wsBlank);
 	P.Expr(p.Ident);
-	P.newlines = 1;
+	P.Newline(1);
 	for i := 0; i < len(p.Decls); i++ {
-		P.Decl(p.Decls[i]);
+		P.node(p.Decls[i]);
 	}
-	P.newlines = 1;
+	P.Newline(1);
 }
 
 
 // ----------------------------------------------------------------------------
 // External interface
 
-func Print(writer io.Write, html bool, prog *AST.Program) {
-	// setup
+// Fprint formats node - which must be a *AST.Program, an AST.Decl, an
+// AST.Stat, or an AST.Expr - according to cfg and writes it to w. It
+// returns the number of bytes the printer produced and the first error
+// encountered while writing, if any. Unless RawFormat is set, that
+// count is of the bytes handed to the tabwriter in front of w; the
+// alignment padding the tabwriter adds is not counted.
+func (cfg *Config) Fprint(w io.Write, node interface{}) (int, os.Error) {
 	var P Printer;
-	padchar := byte(' ');
-	if *usetabs {
-		padchar = '\t';
-	}
-	text := tabwriter.New(writer, *tabwidth, 1, padchar, true, html);
-	P.Init(text, html, prog.Comments);
 
-	// TODO would be better to make the name of the src file be the title
-	P.HtmlPrologue("package " + prog.Ident.(*AST.Ident).Obj.Ident);
-	P.Program(prog);
-	P.HtmlEpilogue();
+	// set up the output: either straight to w (RawFormat), or
+	// through a tabwriter that aligns columns and expands the
+	// formfeed/tab separators the printer emits
+	tabwidth := cfg.Tabwidth;
+	if tabwidth == 0 {
+		tabwidth = 8;
+	}
+	padchar := byte('\t');
+	if cfg.Mode&UseSpaces != 0 {
+		padchar = ' ';
+	}
+	var tw *tabwriter.Writer;
+	text := w;
+	if cfg.Mode&RawFormat == 0 {
+		tw = tabwriter.New(w, tabwidth, 1, padchar, true, cfg.Mode&GenHTML != 0);
+		text = tw;
+	}
 
+	switch n := node.(type) {
+	case *AST.Program:
+		P.Init(text, *cfg, n.Comments);
+		// TODO would be better to make the name of the src file be the title
+		P.HtmlPrologue("package " + n.Ident.(*AST.Ident).Obj.Ident);
+		P.Program(n);
+		P.HtmlEpilogue();
+	case AST.Decl:
+		P.Init(text, *cfg, nil);
+		P.node(n);
+	case AST.Stat:
+		P.Init(text, *cfg, nil);
+		P.node(n);
+	case AST.Expr:
+		P.Init(text, *cfg, nil);
+		P.Expr(n);
+	default:
+		return 0, os.ErrorString("Fprint: cannot print node of this type");
+	}
 	P.String(0, "");  // flush pending separator/newlines
-	err := text.Flush();
+
+	if tw != nil {
+		if err := tw.Flush(); err != nil && P.err == nil {
+			P.err = err;
+		}
+	}
+	return P.written, P.err;
+}
+
+
+// Print formats prog as HTML or plain text (per html) using an 8-column
+// tab width and writes it to writer, panicking if a write fails. It is
+// a thin, backward-compatible wrapper around Fprint; new callers should
+// use Fprint directly so they can choose a Config and handle errors.
+func Print(writer io.Write, html bool, prog *AST.Program) {
+	cfg := Config{Mode: RespectNewlines | PrintComments, Tabwidth: 8};
+	if html {
+		cfg.Mode |= GenHTML;
+	}
+	_, err := cfg.Fprint(writer, prog);
 	if err != nil {
 		panic("print error - exiting");
 	}
@@ -0,0 +1,158 @@
+// Copyright 2009 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package Printer
+
+import (
+	"os";
+	"bytes";
+	"time";
+	"testing";
+	Scanner "scanner";
+	AST "ast";
+)
+
+
+// discard is an io.Write sink that throws away everything written to it,
+// so benchmarks measure formatting cost rather than the cost of actually
+// storing the output.
+type discard struct{}
+
+func (discard) Write(p []byte) (int, os.Error) {
+	return len(p), nil;
+}
+
+
+// deepExpr returns a deeply left-nested binary expression
+//
+//	(((0 + 1) + 1) + ... + 1)
+//
+// depth levels deep - a cheap stand-in for a "large real-world" tree that
+// still stresses Expr1's recursive descent and TaggedString's separator
+// and comment handling.
+func deepExpr(depth int) AST.Expr {
+	var x AST.Expr = &AST.BasicLit{Val: "0"};
+	for i := 0; i < depth; i++ {
+		x = &AST.BinaryExpr{Tok: Scanner.ADD, X: x, Y: &AST.BasicLit{Val: "1"}};
+	}
+	return x;
+}
+
+
+// longList returns a flat comma-separated list of n literals, modeled
+// the way the parser represents an argument list (nested BinaryExprs
+// with Tok == Scanner.COMMA; see DoBinaryExpr).
+func longList(n int) AST.Expr {
+	var x AST.Expr = &AST.BasicLit{Val: "0"};
+	for i := 1; i < n; i++ {
+		x = &AST.BinaryExpr{Tok: Scanner.COMMA, X: x, Y: &AST.BasicLit{Val: "0"}};
+	}
+	return x;
+}
+
+
+func BenchmarkFprintDeepExpr(b *testing.B) {
+	cfg := Config{Mode: RespectNewlines | PrintComments, Tabwidth: 8};
+	x := deepExpr(1000);
+	b.ResetTimer();
+	for i := 0; i < b.N; i++ {
+		cfg.Fprint(discard{}, x);
+	}
+}
+
+
+func BenchmarkFprintLongList(b *testing.B) {
+	cfg := Config{Mode: RespectNewlines | PrintComments, Tabwidth: 8};
+	x := longList(1000);
+	b.ResetTimer();
+	for i := 0; i < b.N; i++ {
+		cfg.Fprint(discard{}, x);
+	}
+}
+
+
+// elapsed formats x and returns how long that took, in nanoseconds.
+func elapsed(t *testing.T, cfg *Config, x AST.Expr) int64 {
+	t0 := time.Nanoseconds();
+	if _, err := cfg.Fprint(discard{}, x); err != nil {
+		t.Fatalf("Fprint failed: %v", err);
+	}
+	return time.Nanoseconds() - t0;
+}
+
+
+// TestNoQuadraticBlowup guards against the pretty printer regressing to
+// quadratic behavior (the kind htmlEscape used to have, building up
+// s[0:i] + esc + htmlEscape(s[i+1:]) on every match) by comparing the
+// time it takes to format a pathologically deep expression against a
+// much smaller one of the same shape. Formatting cost should scale
+// roughly linearly with size; this test only fails if it looks
+// super-linear by a wide margin.
+func TestNoQuadraticBlowup(t *testing.T) {
+	const small = 50;
+	const large = 2000;
+	const scale = large/small;
+
+	cfg := Config{Mode: RespectNewlines | PrintComments, Tabwidth: 8};
+
+	base := elapsed(t, &cfg, deepExpr(small));
+	if base == 0 {
+		base = 1;	// guard against a zero-resolution clock
+	}
+	got := elapsed(t, &cfg, deepExpr(large));
+
+	if got > base*scale*10 {
+		t.Fatalf("formatting a %dx bigger expression took %dx longer (%d ns vs %d ns) - possible quadratic blowup", scale, got/base, got, base);
+	}
+}
+
+
+// sprint formats x in raw mode (no tabwriter, no html) and returns the
+// result as a string, for exact comparison against the expected layout.
+func sprint(t *testing.T, x AST.Expr) string {
+	var buf bytes.Buffer;
+	cfg := Config{Mode: RawFormat};
+	if _, err := cfg.Fprint(&buf, x); err != nil {
+		t.Fatalf("Fprint failed: %v", err);
+	}
+	return buf.String();
+}
+
+
+// lit is a shorthand for an identifier-like leaf in the trees below; a
+// BasicLit prints its Val verbatim, which is all these tests need.
+func lit(name string) AST.Expr {
+	return &AST.BasicLit{Val: name};
+}
+
+
+func TestSliceExpr(t *testing.T) {
+	// x[Low:High] / x[Low:High:Max], with Low, High, Max nilable.
+	tests := []struct {
+		x    *AST.SliceType;
+		want string;
+	}{
+		{&AST.SliceType{X: lit("a")}, "a[:]"},
+		{&AST.SliceType{X: lit("a"), Low: lit("i")}, "a[i:]"},
+		{&AST.SliceType{X: lit("a"), High: lit("j")}, "a[:j]"},
+		{&AST.SliceType{X: lit("a"), Low: lit("i"), High: lit("j")}, "a[i:j]"},
+		{&AST.SliceType{X: lit("a"), Low: lit("i"), High: lit("j"), Max: lit("k")}, "a[i:j:k]"},
+	}
+	for _, test := range tests {
+		if got := sprint(t, test.x); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want);
+		}
+	}
+}
+
+
+// TestArrayTypeSliceForm makes sure the pre-existing `[]T` slice-type
+// form (AST.ArrayType with a nil Len) still renders correctly and isn't
+// affected by the SliceType (slice expression) changes above.
+func TestArrayTypeSliceForm(t *testing.T) {
+	x := &AST.ArrayType{Elt: lit("int")};
+	if got, want := sprint(t, x), "[]int"; got != want {
+		t.Errorf("got %q, want %q", got, want);
+	}
+}